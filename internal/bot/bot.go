@@ -1,5 +1,8 @@
 package bot
 
+import "context"
+
 type Bot interface {
-	StartBot() error
+	// StartBot runs the bot until ctx is done, then shuts it down gracefully
+	StartBot(ctx context.Context) error
 }
@@ -1,61 +1,119 @@
 package tg
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 	"youtube_downloader/internal/bot/tg/handler"
+	"youtube_downloader/internal/bot/tg/handler/youtube"
 	_ "youtube_downloader/internal/database-client"
 	database_client "youtube_downloader/internal/database-client"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/queue"
+	"youtube_downloader/internal/ratelimit"
+	"youtube_downloader/internal/store"
 )
 
 // TgBot uses telegram-Bot-api to maintain tg Bot
 // It can download and send video with different formats (video/audio; quality) by handlers
 type TgBot struct {
-	Bot          *tgbotapi.BotAPI
-	handlers     []handler.Handler
-	Client       *database_client.Client
-	translations map[string]map[string]string
+	Bot        *tgbotapi.BotAPI
+	handlers   []handler.Handler
+	Client     *database_client.Client
+	localizer  *locale.Localizer
+	languages  *languageCache
+	jobs       *queue.Queue
+	store      *store.Store
+	limiter    *ratelimit.Limiter
+	tiers      *tierCache
+	admins     map[int64]bool // ids of users allowed to run admin commands
+	bans       map[string]bool
+	bansMu     sync.RWMutex
+	broadcasts *broadcaster
+
+	webhookServer *http.Server // receives updates in the webhook mode
+	metricsServer *http.Server // serves metrics at METRICS_ADDR
 }
 
+const (
+	defaultQueueWorkers   = 4
+	defaultQueueUserLimit = 2
+	defaultRecordWorkers  = 1                  // recordings of live streams run on their own workers, they take hours
+	finishedJobsTTL       = 7 * 24 * time.Hour // finished jobs are kept in the store for a week
+	keyboardsCleanup      = time.Hour          // expired payloads of buttons and selections are deleted this often
+	selectionsTTL         = 7 * 24 * time.Hour // selections of playlists which weren't changed for a week are deleted
+	defaultShutdownTime   = time.Minute        // running jobs are waited for before they're interrupted
+	defaultLocalesDir     = "cmd/locales"
+)
+
 var (
 	instance *TgBot
 	once     sync.Once
 )
 
-// LoadTranslations loads language files into memory
+// LoadTranslations loads every language file of LOCALES_DIR into memory and reports keys missing in them.
+// DEFAULT_LANGUAGE is used for unsupported languages and missing keys
 func (tb *TgBot) LoadTranslations() error {
-	languages := []string{"en", "ru"}
-	tb.translations = make(map[string]map[string]string)
-
-	for _, lang := range languages {
-		filePath := fmt.Sprintf("cmd/locales/%s.json", lang)
-		file, err := os.Open(filePath)
-		if err != nil {
-			return fmt.Errorf("could not open translation file: %v", err)
-		}
-		defer file.Close()
+	dir := os.Getenv("LOCALES_DIR")
+	if dir == "" {
+		dir = defaultLocalesDir
+	}
+	defaultLanguage := os.Getenv("DEFAULT_LANGUAGE")
+	if defaultLanguage == "" {
+		defaultLanguage = locale.DefaultLanguage
+	}
 
-		var translation map[string]string
-		if err := json.NewDecoder(file).Decode(&translation); err != nil {
-			return fmt.Errorf("could not decode translation file: %v", err)
+	localizer, err := locale.Load(dir, defaultLanguage)
+	if err != nil {
+		return err
+	}
+	for _, lang := range localizer.Languages() {
+		if missing := localizer.Missing()[lang]; len(missing) > 0 {
+			log.Printf("translation %s misses %d keys, %s ones are used: %s",
+				lang, len(missing), defaultLanguage, strings.Join(missing, ", "))
 		}
-
-		tb.translations[lang] = translation
 	}
+	log.Printf("loaded translations: %s", strings.Join(localizer.Languages(), ", "))
+	tb.localizer = localizer
 	return nil
 }
 
+// translations return translations of the language by keys, ones of the default language for an unsupported language
+func (tb *TgBot) translations(lang string) map[string]string {
+	return tb.localizer.Translations(lang)
+}
+
 // NewBot initializes a new TgBot instance with the given Telegram Bot API instance.
 func newBot(bot *tgbotapi.BotAPI) *TgBot {
 	return &TgBot{
 		Bot:    bot,
 		Client: database_client.NewClient(bot.Token),
+		jobs: queue.New(envInt("QUEUE_WORKERS", defaultQueueWorkers),
+			envInt("QUEUE_USER_LIMIT", defaultQueueUserLimit)),
+		limiter:    newLimiter(),
+		tiers:      &tierCache{tiers: make(map[int64]cachedTier)},
+		admins:     parseAdminIDs(os.Getenv("ADMIN_IDS")),
+		bans:       make(map[string]bool),
+		broadcasts: newBroadcaster(),
+		languages:  &languageCache{languages: make(map[int64]string)},
+	}
+}
+
+// envInt return the environment variable as a number or def if it isn't set or invalid
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 1 {
+		return def
 	}
+	return value
 }
 
 // BotInstance returns the singleton instance of TgBot.
@@ -68,13 +126,16 @@ func BotInstance(bot *tgbotapi.BotAPI) *TgBot {
 }
 
 // StartBot starts the Bot by authorizing it and initiating the update handling process.
-func (tb *TgBot) StartBot() error {
+// When ctx is done, the bot stops receiving updates and shuts down gracefully
+func (tb *TgBot) StartBot(ctx context.Context) error {
 	log.Printf("Authorized on account %s", tb.Bot.Self.UserName)
 
 	// Load translations
 	if err := tb.LoadTranslations(); err != nil {
 		log.Fatal("Error loading translations:", err)
 	}
+	// descriptions of commands are taken from the translations
+	tb.SetCommands()
 
 	dir, err := os.Getwd()
 	if err != nil {
@@ -84,19 +145,136 @@ func (tb *TgBot) StartBot() error {
 		log.Println(err.Error())
 	}
 
+	tb.store, err = openStore()
+	if err != nil {
+		log.Fatal("Error opening store:", err)
+	}
+	if err = tb.loadBans(); err != nil {
+		log.Println("Error loading bans:", err)
+	}
+	go tb.deleteExpiredKeyboards(ctx)
+	tb.jobs.OnStateChange(tb.saveJobState)
+	tb.jobs.AddWorkers(youtube.RecordingClass, envInt("RECORD_WORKERS", defaultRecordWorkers))
+	tb.jobs.Start(context.Background())
 	tb.initSupportedHandlers()
+	tb.resumeJobs()
+	tb.startMetrics()
 
-	updates := tb.initUpdatesChannel()
-	tb.handleUpdates(updates)
+	updates, err := tb.initUpdatesChannel()
+	if err != nil {
+		return err
+	}
+
+	tb.handleUpdates(ctx, updates)
+	log.Println("Shutting down: stop receiving updates")
+	tb.stopReceivingUpdates()
+
+	return tb.shutdown(dir)
+}
+
+// stopReceivingUpdates stops polling or the webhook server
+func (tb *TgBot) stopReceivingUpdates() {
+	if tb.webhookServer == nil {
+		tb.Bot.StopReceivingUpdates()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tb.stopWebhook(ctx)
+}
+
+// shutdown stops a running broadcast and waits for running jobs for SHUTDOWN_TIMEOUT seconds and interrupts the rest of them.
+// Unfinished jobs stay in the store to be resumed after a restart, their users are notified about it.
+// Then temp files in download dirs are removed and the store is closed
+func (tb *TgBot) shutdown(dir string) error {
+	tb.broadcasts.stop()
 
+	timeout := time.Duration(envInt("SHUTDOWN_TIMEOUT", int(defaultShutdownTime.Seconds()))) * time.Second
+	log.Printf("Shutting down: waiting for running jobs up to %s", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := tb.jobs.Shutdown(ctx); err != nil {
+		log.Printf("Running jobs are interrupted: %s", err)
+	}
+
+	tb.notifyUnfinishedJobs()
+	tb.stopMetrics()
+
+	if err := clearDownloadDirs(dir); err != nil {
+		log.Println(err.Error())
+	}
+	if err := tb.store.Close(); err != nil {
+		return fmt.Errorf("could not close store: %w", err)
+	}
+	log.Println("Bot is stopped")
 	return nil
 }
 
+// notifyUnfinishedJobs tells every user with unfinished jobs that they continue after the restart.
+// Jobs which can't be resumed aren't counted, their users are asked to press the button again after the restart
+func (tb *TgBot) notifyUnfinishedJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	records, err := tb.store.UnfinishedJobs(ctx)
+	if err != nil {
+		log.Printf("can't get unfinished jobs: %s", err)
+		return
+	}
+
+	// a single message per chat, so a queued playlist doesn't flood the chat
+	counts := make(map[int64]int)
+	languages := make(map[int64]string)
+	for _, record := range records {
+		if !youtube.Resumable(record.Kind) {
+			continue
+		}
+		counts[record.ChatID]++
+		languages[record.ChatID] = record.LanguageCode
+	}
+	for chatID, count := range counts {
+		translations := tb.translations(languages[chatID])
+		msg := tgbotapi.NewMessage(chatID, locale.Plural(translations, "shutdownNotification", count, nil))
+		if _, err := tb.Bot.Send(msg); err != nil {
+			log.Printf("can't notify chat %d about shutdown: %s", chatID, err)
+		}
+	}
+}
+
+// deleteExpiredKeyboards deletes expired payloads of buttons and selections of playlists from the store
+// at start and then periodically until ctx is done
+func (tb *TgBot) deleteExpiredKeyboards(ctx context.Context) {
+	ticker := time.NewTicker(keyboardsCleanup)
+	defer ticker.Stop()
+	for {
+		deleted, err := tb.store.DeleteExpiredCallbacks(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			log.Printf("can't delete expired callbacks: %s", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d expired callbacks", deleted)
+		}
+		deleted, err = tb.store.DeleteSelectionsBefore(ctx, time.Now().Add(-selectionsTTL))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("can't delete old selections: %s", err)
+		} else if deleted > 0 {
+			log.Printf("Deleted %d old selections", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // initSupportedHandlers initializes all supported handlers for the Telegram bot
 // according to SupportedHandlers
 func (tb *TgBot) initSupportedHandlers() {
 	for _, handlerType := range handler.SupportedHandlers {
-		handler := handler.CreateHandler(handlerType)
+		handler := handler.CreateHandler(handlerType, tb.jobs, tb.store)
 		tb.registerHandler(&handler)
 	}
 }
@@ -106,13 +284,79 @@ func (tb *TgBot) registerHandler(handler *handler.Handler) {
 	tb.handlers = append(tb.handlers, *handler)
 }
 
+// openStore opens the local store by STORE_PATH
+func openStore() (*store.Store, error) {
+	path := os.Getenv("STORE_PATH")
+	if path == "" {
+		path = store.DefaultPath
+	}
+	return store.Open(path)
+}
+
+// saveJobState saves the new state of the job to the store
+func (tb *TgBot) saveJobState(job *queue.Job, state queue.State) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tb.store.SetJobState(ctx, job.ID, string(state)); err != nil {
+		log.Printf("can't save state of job %s: %s", job.ID, err)
+	}
+}
+
+// resumeJobs adds jobs which were queued or running before the restart back to the queue.
+// Their downloads start over, so partial files left in download dirs aren't needed.
+// Jobs which can't be resumed are failed, their users get a message about it
+func (tb *TgBot) resumeJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := tb.store.DeleteFinishedJobs(ctx, time.Now().Add(-finishedJobsTTL)); err != nil {
+		log.Printf("can't delete finished jobs: %s", err)
+	}
+
+	records, err := tb.store.UnfinishedJobs(ctx)
+	if err != nil {
+		log.Printf("can't get unfinished jobs: %s", err)
+		return
+	}
+
+	for _, record := range records {
+		translations := tb.translations(record.LanguageCode)
+		if err := tb.handlers[handler.YoutubeHandler].ResumeJob(record, tb.Bot, tb.Client, &translations); err != nil {
+			log.Println(err)
+			if err := tb.store.SetJobState(ctx, record.ID, string(queue.StateFailed)); err != nil {
+				log.Printf("can't save state of job %s: %s", record.ID, err)
+			}
+		}
+	}
+	if len(records) > 0 {
+		log.Printf("%d unfinished jobs are handled after the restart", len(records))
+	}
+}
+
+// allowedUpdates are the types of updates handled by the bot, other ones aren't sent by Telegram.
+// Successful payments come as messages
+var allowedUpdates = []string{"message", "callback_query", "inline_query", "pre_checkout_query"}
+
 // initUpdatesChannel initializes the update channel for receiving updates from the Telegram server.
+// BOT_MODE selects long polling (by default) or the webhook.
 // It configures the update retrieval settings and returns the update channel.
-func (tb *TgBot) initUpdatesChannel() tgbotapi.UpdatesChannel {
+func (tb *TgBot) initUpdatesChannel() (tgbotapi.UpdatesChannel, error) {
+	switch mode := os.Getenv("BOT_MODE"); mode {
+	case modeWebhook:
+		return tb.initWebhook()
+	case modePolling, "":
+		if err := tb.deleteWebhook(); err != nil {
+			log.Printf("can't delete webhook: %s", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown BOT_MODE %q, expected %s or %s", mode, modePolling, modeWebhook)
+	}
+
 	update := tgbotapi.NewUpdate(0)
 	update.Timeout = 60
+	update.AllowedUpdates = allowedUpdates
 
-	return tb.Bot.GetUpdatesChan(update)
+	return tb.Bot.GetUpdatesChan(update), nil
 }
 
 func clearDownloadDir() error {
@@ -165,17 +409,38 @@ func clearDownloadDirs(rootDir string) error {
 	return nil
 }
 
-// SetCommands sets the commands for the bot
+// SetCommands sets the commands for the bot with descriptions in every supported language.
+// Users of other languages see descriptions in the default language
 func (tb *TgBot) SetCommands() {
+	tb.setCommands(tb.localizer.DefaultLanguage(), "")
+	for _, lang := range tb.localizer.Languages() {
+		tb.setCommands(lang, lang)
+	}
+}
+
+// setCommands sets the commands with descriptions in the language for users with the language code,
+// an empty code sets them for users of any language
+func (tb *TgBot) setCommands(lang string, languageCode string) {
+	translations := tb.translations(lang)
 	commands := []tgbotapi.BotCommand{
-		{Command: commandStart, Description: "Start the bot"},
-		{Command: commandHelp, Description: "Get help"},
-		{Command: commandPay, Description: "Subscribe to premium features"},
-		{Command: commandStatus, Description: "Send user premium subscription status"},
+		{Command: commandStart, Description: translations["commandStart"]},
+		{Command: commandHelp, Description: translations["commandHelp"]},
+		{Command: commandPay, Description: translations["commandPay"]},
+		{Command: commandStatus, Description: translations["commandStatus"]},
+		{Command: commandQueue, Description: translations["commandQueue"]},
+		{Command: commandSettings, Description: translations["commandSettings"]},
+		{Command: commandLanguage, Description: translations["commandLanguage"]},
 	}
 
-	config := tgbotapi.NewSetMyCommands(commands...)
+	config := tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeDefault(), languageCode, commands...)
+	if _, err := tb.Bot.Request(config); err != nil {
+		log.Printf("Error setting bot commands in %q: %s", languageCode, err)
+	}
+	groupCommands := append(commands[:len(commands):len(commands)],
+		tgbotapi.BotCommand{Command: commandAuto, Description: translations["commandAuto"]})
+	config = tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeAllGroupChats(), languageCode, groupCommands...)
 	if _, err := tb.Bot.Request(config); err != nil {
-		log.Println("Error setting bot commands:", err)
+		log.Printf("Error setting group commands in %q: %s", languageCode, err)
 	}
+	tb.setAdminCommands(commands, translations, languageCode)
 }
@@ -0,0 +1,127 @@
+package tg
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"strings"
+	"time"
+	"youtube_downloader/internal/bot/tg/send"
+)
+
+// commandAuto turns on or off handling of every link in a group
+const commandAuto = "auto"
+
+// isGroup return true if the chat is a group or a supergroup
+func isGroup(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// isAddressed return true if the message mentions the bot or replies to its message
+func (tb *TgBot) isAddressed(message *tgbotapi.Message) bool {
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == tb.Bot.Self.ID {
+		return true
+	}
+
+	mention := "@" + strings.ToLower(tb.Bot.Self.UserName)
+	for _, text := range []string{message.Text, message.Caption} {
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			if strings.TrimRight(word, ".,;:!?") == mention {
+				return true
+			}
+		}
+	}
+	for _, entity := range append(message.Entities, message.CaptionEntities...) {
+		if entity.Type == "text_mention" && entity.User != nil && entity.User.ID == tb.Bot.Self.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// isForBot return true if the message is for the bot.
+// In a group the bot reacts only to its commands and when it's mentioned, replied to or the auto mode is on
+func (tb *TgBot) isForBot(message *tgbotapi.Message) bool {
+	if !isGroup(message.Chat) || message.SuccessfulPayment != nil {
+		return true
+	}
+	if message.IsCommand() {
+		return !tb.isForOtherBot(message)
+	}
+	return tb.shouldHandleGroupMessage(message)
+}
+
+// shouldHandleGroupMessage return true if the bot is addressed by the message in the group or its auto mode is on
+func (tb *TgBot) shouldHandleGroupMessage(message *tgbotapi.Message) bool {
+	if tb.isAddressed(message) {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	on, err := tb.store.GroupAutoMode(ctx, message.Chat.ID)
+	if err != nil {
+		log.Printf("can't get auto mode of chat %d: %s", message.Chat.ID, err)
+	}
+	return on
+}
+
+// isForOtherBot return true if the command is addressed to another bot, i.e. /start@other_bot
+func (tb *TgBot) isForOtherBot(message *tgbotapi.Message) bool {
+	_, botName, ok := strings.Cut(message.CommandWithAt(), "@")
+	return ok && !strings.EqualFold(botName, tb.Bot.Self.UserName)
+}
+
+// isKeyboardOwner return true if the user who pressed the button of a group message is the one it replies to.
+// Keyboards are sent in groups in reply to the requester, so buttons of the group message belong to the user
+// it replies to and other members of the group can't use them.
+// A keyboard which isn't a reply to a user, i.e. a keyboard of a playlist sent in reply to the bot's message,
+// can be pressed by anyone, its buttons check the requester saved with their payload instead
+func isKeyboardOwner(callbackQuery *tgbotapi.CallbackQuery) bool {
+	message := callbackQuery.Message
+	if message == nil || !isGroup(message.Chat) {
+		return true
+	}
+	requester := message.ReplyToMessage
+	return requester == nil || requester.From == nil || requester.From.ID == callbackQuery.From.ID
+}
+
+// handleAutoCommand toggles the auto mode of the group, it's allowed to admins of the group only
+func (tb *TgBot) handleAutoCommand(message *tgbotapi.Message, lang string) {
+	translations := tb.translations(lang)
+	if !isGroup(message.Chat) {
+		send.SendMessage(tb.Bot, message, translations["autoModeGroupsOnly"])
+		return
+	}
+
+	member, err := tb.Bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: message.Chat.ID, UserID: message.From.ID},
+	})
+	if err != nil {
+		log.Printf("can't get member %d of chat %d: %s", message.From.ID, message.Chat.ID, err)
+	}
+	if !member.IsCreator() && !member.IsAdministrator() {
+		adminsOnly := translations["autoModeAdminsOnly"]
+		send.SendReplyMessage(tb.Bot, message, &adminsOnly)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	on, err := tb.store.GroupAutoMode(ctx, message.Chat.ID)
+	if err == nil {
+		err = tb.store.SetGroupAutoMode(ctx, message.Chat.ID, !on)
+	}
+	if err != nil {
+		log.Printf("can't toggle auto mode of chat %d: %s", message.Chat.ID, err)
+		somethingWentWrong := translations["somethingWentWrong"]
+		send.SendReplyMessage(tb.Bot, message, &somethingWentWrong)
+		return
+	}
+
+	text := translations["autoModeOn"]
+	if on {
+		text = translations["autoModeOff"]
+	}
+	send.SendReplyMessage(tb.Bot, message, &text)
+}
@@ -0,0 +1,32 @@
+package tg
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsAddressed(t *testing.T) {
+	tb := &TgBot{Bot: &tgbotapi.BotAPI{Self: tgbotapi.User{ID: 1, UserName: "YoutubeBot"}}}
+
+	assert.True(t, tb.isAddressed(&tgbotapi.Message{Text: "@youtubebot, https://youtu.be/x"}))
+	assert.True(t, tb.isAddressed(&tgbotapi.Message{Caption: "look @YoutubeBot"}))
+	assert.True(t, tb.isAddressed(&tgbotapi.Message{ReplyToMessage: &tgbotapi.Message{From: &tgbotapi.User{ID: 1}}}))
+	assert.False(t, tb.isAddressed(&tgbotapi.Message{Text: "@youtubebot2 https://youtu.be/x"}))
+	assert.False(t, tb.isAddressed(&tgbotapi.Message{ReplyToMessage: &tgbotapi.Message{From: &tgbotapi.User{ID: 2}}}))
+}
+
+func TestIsKeyboardOwner(t *testing.T) {
+	group := &tgbotapi.Chat{ID: -1, Type: "supergroup"}
+	keyboard := &tgbotapi.Message{Chat: group, ReplyToMessage: &tgbotapi.Message{From: &tgbotapi.User{ID: 10}}}
+
+	assert.True(t, isKeyboardOwner(&tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 10}, Message: keyboard}))
+	assert.False(t, isKeyboardOwner(&tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 20}, Message: keyboard}))
+
+	// a keyboard which isn't a reply is checked by the requester of its buttons
+	notReply := &tgbotapi.Message{Chat: group}
+	assert.True(t, isKeyboardOwner(&tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 20}, Message: notReply}))
+
+	private := &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 10, Type: "private"}}
+	assert.True(t, isKeyboardOwner(&tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 10}, Message: private}))
+}
@@ -13,36 +13,88 @@ import (
 	database_client "youtube_downloader/internal/database-client"
 )
 
-// handleUpdates gets updates from telegramAPI and handles it
-func (tb *TgBot) handleUpdates(updates tgbotapi.UpdatesChannel) {
-	for update := range updates {
-		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-		defer cancel()
-
-		if err := tb.ensureUserExists(ctx, update.Message); err != nil {
-			log.Println(err)
+// handleUpdates gets updates from telegramAPI and handles it until ctx is done.
+// Downloads run in the queue, so handling of an update never waits for a download
+func (tb *TgBot) handleUpdates(ctx context.Context, updates tgbotapi.UpdatesChannel) {
+	for {
+		// an update being handled is finished before the shutdown
+		if ctx.Err() != nil {
+			return
 		}
 
-		switch {
-		case update.Message != nil && update.Message.SuccessfulPayment == nil:
-			if update.Message.IsCommand() {
-				tb.handleCommand(update.Message)
-				continue
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
 			}
-			tb.handleMessage(update.Message)
-		case update.CallbackQuery != nil:
-			tb.handleCallbackQuery(update.CallbackQuery)
-		case update.PreCheckoutQuery != nil:
-			tb.handlePreCheckoutQuery(update.PreCheckoutQuery)
-		case update.Message != nil && update.Message.SuccessfulPayment != nil:
-			tb.handleSuccessfulPayment(update.Message)
-		default:
-			log.Println("unknown user's message")
-			tb.handleDefaultCommand(update.Message, update.Message.From.LanguageCode)
+			tb.handleUpdate(update)
 		}
 	}
 }
 
+// handleUpdate handles a single update by its type
+func (tb *TgBot) handleUpdate(update tgbotapi.Update) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	// messages of groups which aren't for the bot are skipped before anything else
+	if update.Message != nil && !tb.isForBot(update.Message) {
+		return
+	}
+
+	// bans and limits go first, so spam doesn't reach the database and YouTube
+	if user := updateUser(update); user != nil {
+		if tb.isBanned(user) {
+			log.Printf("[%s] is banned, update %d is ignored", user.UserName, update.UpdateID)
+			return
+		}
+		if !tb.isAdmin(user.ID) && !tb.allowUpdate(ctx, update, user) {
+			return
+		}
+	}
+	// the rest of the update is handled in the language chosen by the user
+	tb.setLanguage(update)
+	tb.saveChat(update)
+
+	if err := tb.ensureUserExists(ctx, update.Message); err != nil {
+		log.Println(err)
+	}
+
+	switch {
+	case update.Message != nil && update.Message.SuccessfulPayment == nil:
+		if update.Message.IsCommand() {
+			tb.handleCommand(update.Message)
+			return
+		}
+		tb.handleMessage(update.Message)
+	case update.CallbackQuery != nil:
+		tb.handleCallbackQuery(update.CallbackQuery)
+	case update.InlineQuery != nil:
+		tb.handleInlineQuery(update.InlineQuery)
+	case update.PreCheckoutQuery != nil:
+		tb.handlePreCheckoutQuery(update.PreCheckoutQuery)
+	case update.Message != nil && update.Message.SuccessfulPayment != nil:
+		tb.handleSuccessfulPayment(update.Message)
+	default:
+		log.Printf("update %d of unknown type is skipped", update.UpdateID)
+	}
+}
+
+// updateUser return the user who sent the update or nil for payments and unknown updates
+func updateUser(update tgbotapi.Update) *tgbotapi.User {
+	switch {
+	case update.Message != nil && update.Message.SuccessfulPayment == nil:
+		return update.Message.From
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From
+	case update.InlineQuery != nil:
+		return update.InlineQuery.From
+	}
+	return nil
+}
+
 func (tb *TgBot) handlePreCheckoutQuery(preCheckoutQuery *tgbotapi.PreCheckoutQuery) {
 	preCheckoutConfig := tgbotapi.PreCheckoutConfig{
 		PreCheckoutQueryID: preCheckoutQuery.ID,
@@ -54,44 +106,57 @@ func (tb *TgBot) handlePreCheckoutQuery(preCheckoutQuery *tgbotapi.PreCheckoutQu
 }
 
 // handleMessage processes an incoming message.
-// If the message contains a command, it handles the command.
-// If the message contains a link, it handles the link.
+// Every YouTube link found in the message is handled separately.
 // Otherwise, it handles default and help commands.
 func (tb *TgBot) handleMessage(message *tgbotapi.Message) {
 	log.Printf("[%s] %s", message.From.UserName, message.Text)
 
-	switch {
-	case isYoutubeLink(message.Text):
-		keyboard, err := tb.handlers[handler.YoutubeHandler].HandleMessage(message)
-		lang := message.From.LanguageCode
-		if err != nil {
-			log.Print(err)
-			errMsg := err.Error()
-			if errMsg == "Request Entity Too Large" {
-				fileTooLarge := tb.translations[lang]["fileTooLarge"]
-				send.SendReplyMessage(tb.Bot, message, &fileTooLarge)
-			} else if errMsg == "extractVideoID failed: invalid characters in video id" {
-				invalidLink := tb.translations[lang]["invalidLink"]
-				send.SendReplyMessage(tb.Bot, message, &invalidLink)
-			} else {
-				somethingWentWrong := tb.translations[lang]["somethingWentWrong"]
-				send.SendReplyMessage(tb.Bot, message, &somethingWentWrong)
+	if tb.isAdmin(message.From.ID) && tb.takeBroadcastMessage(message) {
+		return
+	}
+
+	links := extractLinks(message)
+	if len(links) == 0 {
+		if isGroup(message.Chat) {
+			// the auto mode only handles links, other messages of the group are for its members
+			if tb.isAddressed(message) {
+				tb.handleHelpCommand(message, message.From.LanguageCode)
 			}
 			return
 		}
-
-		if strings.HasPrefix(message.Text, "https://www.youtube.com/live/") {
-			videoURL := youtube.FormatYouTubeURLOnStream(message.Text)
-			translations := tb.translations[lang]
-			send.SendKeyboardMessageReplyWithFormattedLink(tb.Bot, message, keyboard, videoURL, translations)
-		} else {
-			translations := tb.translations[lang]
-			send.SendKeyboardMessageReply(tb.Bot, message, keyboard, &translations)
-		}
-
-	default:
 		tb.handleDefaultCommand(message, message.From.LanguageCode)
 		tb.handleHelpCommand(message, message.From.LanguageCode)
+		return
+	}
+
+	for _, link := range links {
+		tb.handleLink(message, link)
+	}
+}
+
+// handleLink sends the user a keyboard for a single link from the message,
+// nothing is sent if the download is queued at once by the user's settings
+func (tb *TgBot) handleLink(message *tgbotapi.Message, link string) {
+	lang := message.From.LanguageCode
+	tr := tb.translations(lang)
+	keyboard, err := tb.handlers[handler.YoutubeHandler].HandleMessage(message, link, tb.Bot, tb.Client, &tr)
+	if err != nil {
+		log.Print(err)
+		errorText := youtube.ErrorText(err, &tr, "somethingWentWrong")
+		send.SendReplyMessage(tb.Bot, message, &errorText)
+		return
+	}
+	if keyboard == nil {
+		return
+	}
+
+	if strings.HasPrefix(link, "https://www.youtube.com/live/") {
+		videoURL := youtube.FormatYouTubeURLOnStream(link)
+		translations := tb.translations(lang)
+		send.SendKeyboardMessageReplyWithFormattedLink(tb.Bot, message, keyboard, videoURL, translations)
+	} else {
+		translations := tb.translations(lang)
+		send.SendKeyboardMessageReply(tb.Bot, message, keyboard, link, &translations)
 	}
 }
 
@@ -110,7 +175,8 @@ func (tb *TgBot) ensureUserExists(ctx context.Context, message *tgbotapi.Message
 		return fmt.Errorf("error checking if user exists: %w", err)
 	}
 	if !exist {
-		newUser := database_client.NewUser(username, message.Chat.ID)
+		// the private chat of the user has the user's id, a group has its own one
+		newUser := database_client.NewUser(username, message.From.ID)
 		if err := tb.Client.CreateUser(ctx, newUser); err != nil {
 			return fmt.Errorf("error creating new user: %w", err)
 		}
@@ -122,5 +188,6 @@ func isYoutubeLink(link string) bool {
 	link = strings.TrimSpace(link)
 	return strings.HasPrefix(link, "https://www.youtube.com") ||
 		strings.HasPrefix(link, "https://youtube.com") ||
+		strings.HasPrefix(link, "https://music.youtube.com") ||
 		strings.HasPrefix(link, "https://youtu.be")
 }
@@ -0,0 +1,352 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"youtube_downloader/internal/bot/tg/send"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/queue"
+	"youtube_downloader/internal/store"
+)
+
+// admin commands are available to users from ADMIN_IDS only
+const (
+	commandStats        = "stats"
+	commandUser         = "user"
+	commandGrant        = "grant"
+	commandResetTraffic = "reset_traffic"
+	commandBan          = "ban"
+	commandUnban        = "unban"
+	commandAudit        = "audit"
+
+	defaultAuditEntries = 20
+	maxAuditEntries     = 100
+)
+
+var errAdminUsage = errors.New("invalid arguments")
+
+// parseAdminIDs parses a comma separated list of Telegram user ids
+func parseAdminIDs(value string) map[int64]bool {
+	admins := make(map[int64]bool)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			log.Printf("ADMIN_IDS: invalid user id %q", field)
+			continue
+		}
+		admins[id] = true
+	}
+	return admins
+}
+
+// isAdmin return true if the user is one of ADMIN_IDS
+func (tb *TgBot) isAdmin(userID int64) bool {
+	return tb.admins[userID]
+}
+
+// handleAdminCommand runs the admin command and records it in the audit log.
+// Other users get the default message as for an unknown command
+func (tb *TgBot) handleAdminCommand(message *tgbotapi.Message, lang string) {
+	if !tb.isAdmin(message.From.ID) {
+		tb.handleDefaultCommand(message, lang)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	args := strings.Fields(message.CommandArguments())
+	var err error
+	switch message.Command() {
+	case commandStats:
+		err = tb.handleStatsCommand(ctx, message, lang)
+	case commandUser:
+		err = tb.handleUserCommand(ctx, message, args, lang)
+	case commandGrant:
+		err = tb.handleGrantCommand(ctx, message, args, lang)
+	case commandResetTraffic:
+		err = tb.handleResetTrafficCommand(ctx, message, args, lang)
+	case commandBan:
+		err = tb.handleBanCommand(ctx, message, args, lang)
+	case commandUnban:
+		err = tb.handleUnbanCommand(ctx, message, args, lang)
+	case commandAudit:
+		err = tb.handleAuditCommand(ctx, message, args, lang)
+	case commandBroadcast:
+		err = tb.handleBroadcastCommand(message, lang)
+	}
+	tb.audit(ctx, message, args, err)
+
+	switch {
+	case errors.Is(err, errAdminUsage):
+		send.SendMessage(tb.Bot, message, tb.translations(lang)["adminUsage"])
+	case err != nil:
+		log.Printf("admin command /%s failed: %s", message.Command(), err)
+		send.SendMessage(tb.Bot, message, locale.Text(tb.translations(lang), "adminError", locale.Params{"error": err}))
+	}
+}
+
+// audit records the admin command with its arguments and result
+func (tb *TgBot) audit(ctx context.Context, message *tgbotapi.Message, args []string, err error) {
+	var target, details string
+	if len(args) > 0 {
+		target = args[0]
+		details = strings.Join(args[1:], " ")
+	}
+	if err != nil {
+		details = strings.TrimSpace(details + " error: " + err.Error())
+	}
+	tb.recordAudit(message.From, message.Command(), target, details)
+}
+
+// recordAudit records the admin's action in the audit log
+func (tb *TgBot) recordAudit(admin *tgbotapi.User, action, target, details string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry := &store.AuditEntry{
+		AdminID:       admin.ID,
+		AdminUsername: admin.UserName,
+		Action:        action,
+		Target:        target,
+		Details:       details,
+	}
+	if err := tb.store.AddAuditEntry(ctx, entry); err != nil {
+		log.Printf("can't record admin action %s: %s", entry.Action, err)
+	}
+	log.Printf("[admin %s] /%s %s %s", entry.AdminUsername, entry.Action, entry.Target, entry.Details)
+}
+
+// handleStatsCommand sends usage stats of the bot
+func (tb *TgBot) handleStatsCommand(ctx context.Context, message *tgbotapi.Message, lang string) error {
+	now := time.Now()
+	day, err := tb.store.JobStatsSince(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	week, err := tb.store.JobStatsSince(ctx, now.Add(-7*24*time.Hour))
+	if err != nil {
+		return err
+	}
+	bans, err := tb.store.Bans(ctx)
+	if err != nil {
+		return err
+	}
+	running, queued := tb.jobs.Len()
+
+	text := locale.Text(tb.translations(lang), "adminStats", locale.Params{
+		"running": running, "queued": queued,
+		"dayUsers": day.Users, "dayJobs": day.Jobs,
+		"dayDone": day.States[string(queue.StateDone)], "dayFailed": day.States[string(queue.StateFailed)],
+		"weekUsers": week.Users, "weekJobs": week.Jobs,
+		"weekDone": week.States[string(queue.StateDone)], "weekFailed": week.States[string(queue.StateFailed)],
+		"banned": len(bans),
+	})
+	return send.SendMessage(tb.Bot, message, text)
+}
+
+// handleUserCommand sends traffic and subscription of the user: /user <username>
+func (tb *TgBot) handleUserCommand(ctx context.Context, message *tgbotapi.Message, args []string, lang string) error {
+	if len(args) != 1 {
+		return errAdminUsage
+	}
+	username := strings.TrimPrefix(args[0], "@")
+
+	user, err := tb.Client.GetUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	text := locale.Text(tb.translations(lang), "adminUser", locale.Params{
+		"username": user.Username,
+		"chatID":   user.ChatID,
+		"traffic":  strconv.FormatFloat(user.Traffic, 'f', 2, 64),
+		"status":   user.Subscription.SubscriptionStatus,
+		"until":    user.Subscription.EndSubscription.Format("2006-01-02"),
+	})
+	if tb.isBanned(&tgbotapi.User{ID: user.ChatID, UserName: user.Username}) {
+		text += "\n" + tb.translations(lang)["adminUserBanned"]
+	}
+	return send.SendMessage(tb.Bot, message, text)
+}
+
+// handleGrantCommand grants or extends a subscription of the user: /grant <username> <month|year|lifetime>
+func (tb *TgBot) handleGrantCommand(ctx context.Context, message *tgbotapi.Message, args []string, lang string) error {
+	if len(args) != 2 {
+		return errAdminUsage
+	}
+	username, duration := strings.TrimPrefix(args[0], "@"), args[1]
+	if duration != "month" && duration != "year" && duration != "lifetime" {
+		return errAdminUsage
+	}
+
+	user, err := tb.Client.GetUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if user.Subscription.EndSubscription.Before(now) {
+		user.Subscription.StartSubscription = now
+		user.Subscription.EndSubscription = addDurationToTime(now, duration)
+	} else {
+		user.Subscription.EndSubscription = addDurationToTime(user.Subscription.EndSubscription, duration)
+	}
+	user.Subscription.SubscriptionStatus = "active"
+	user.Subscription.Duration = duration
+
+	if err := tb.Client.UpdateSubscription(ctx, user); err != nil {
+		return err
+	}
+	// the chat of a user with the bot has the id of the user
+	tb.forgetTier(user.ChatID)
+
+	text := locale.Text(tb.translations(lang), "adminGranted", locale.Params{
+		"username": user.Username, "until": user.Subscription.EndSubscription.Format("2006-01-02")})
+	return send.SendMessage(tb.Bot, message, text)
+}
+
+// handleResetTrafficCommand sets traffic of the user to zero: /reset_traffic <username>
+func (tb *TgBot) handleResetTrafficCommand(ctx context.Context, message *tgbotapi.Message, args []string, lang string) error {
+	if len(args) != 1 {
+		return errAdminUsage
+	}
+	username := strings.TrimPrefix(args[0], "@")
+
+	if err := tb.Client.UpdateTraffic(ctx, username, 0); err != nil {
+		return err
+	}
+	return send.SendMessage(tb.Bot, message, locale.Text(tb.translations(lang), "adminTrafficReset", locale.Params{"username": username}))
+}
+
+// handleBanCommand bans the user by a username or an id: /ban <username|id> [reason].
+// Updates of banned users are ignored
+func (tb *TgBot) handleBanCommand(ctx context.Context, message *tgbotapi.Message, args []string, lang string) error {
+	if len(args) == 0 {
+		return errAdminUsage
+	}
+	target := banTarget(args[0])
+	if id, err := strconv.ParseInt(target, 10, 64); err == nil && tb.isAdmin(id) {
+		return fmt.Errorf("admin %d can't be banned", id)
+	}
+
+	ban := &store.Ban{Target: target, Reason: strings.Join(args[1:], " "), AdminID: message.From.ID}
+	if err := tb.store.AddBan(ctx, ban); err != nil {
+		return err
+	}
+	tb.bansMu.Lock()
+	tb.bans[target] = true
+	tb.bansMu.Unlock()
+
+	return send.SendMessage(tb.Bot, message, locale.Text(tb.translations(lang), "adminBanned", locale.Params{"user": args[0]}))
+}
+
+// handleUnbanCommand unbans the user: /unban <username|id>
+func (tb *TgBot) handleUnbanCommand(ctx context.Context, message *tgbotapi.Message, args []string, lang string) error {
+	if len(args) != 1 {
+		return errAdminUsage
+	}
+	target := banTarget(args[0])
+
+	removed, err := tb.store.RemoveBan(ctx, target)
+	if err != nil {
+		return err
+	}
+	tb.bansMu.Lock()
+	delete(tb.bans, target)
+	tb.bansMu.Unlock()
+
+	if !removed {
+		return send.SendMessage(tb.Bot, message, locale.Text(tb.translations(lang), "adminNotBanned", locale.Params{"user": args[0]}))
+	}
+	return send.SendMessage(tb.Bot, message, locale.Text(tb.translations(lang), "adminUnbanned", locale.Params{"user": args[0]}))
+}
+
+// handleAuditCommand sends the latest records of the audit log: /audit [number]
+func (tb *TgBot) handleAuditCommand(ctx context.Context, message *tgbotapi.Message, args []string, lang string) error {
+	limit := defaultAuditEntries
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return errAdminUsage
+		}
+		limit = min(n, maxAuditEntries)
+	}
+
+	entries, err := tb.store.AuditLog(ctx, limit)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{tb.translations(lang)["adminAuditTitle"]}
+	for _, entry := range entries {
+		line := fmt.Sprintf("%s @%s /%s %s %s", entry.CreatedAt.Format("2006-01-02 15:04"),
+			entry.AdminUsername, entry.Action, entry.Target, entry.Details)
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return send.SendMessage(tb.Bot, message, strings.Join(lines, "\n"))
+}
+
+// banTarget return the key of a ban: a user id or a lowercase username without @
+func banTarget(arg string) string {
+	return strings.ToLower(strings.TrimPrefix(arg, "@"))
+}
+
+// loadBans loads banned users from the store
+func (tb *TgBot) loadBans() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	bans, err := tb.store.Bans(ctx)
+	if err != nil {
+		return err
+	}
+
+	tb.bansMu.Lock()
+	defer tb.bansMu.Unlock()
+	for _, ban := range bans {
+		tb.bans[ban.Target] = true
+	}
+	return nil
+}
+
+// isBanned return true if the user is banned by the id or the username
+func (tb *TgBot) isBanned(user *tgbotapi.User) bool {
+	tb.bansMu.RLock()
+	defer tb.bansMu.RUnlock()
+	if tb.bans[strconv.FormatInt(user.ID, 10)] {
+		return true
+	}
+	return user.UserName != "" && tb.bans[strings.ToLower(user.UserName)]
+}
+
+// setAdminCommands adds admin commands to the menu of every admin for users with the language code
+func (tb *TgBot) setAdminCommands(commands []tgbotapi.BotCommand, translations map[string]string, languageCode string) {
+	commands = append(commands,
+		tgbotapi.BotCommand{Command: commandStats, Description: translations["commandStats"]},
+		tgbotapi.BotCommand{Command: commandUser, Description: translations["commandUser"]},
+		tgbotapi.BotCommand{Command: commandGrant, Description: translations["commandGrant"]},
+		tgbotapi.BotCommand{Command: commandResetTraffic, Description: translations["commandResetTraffic"]},
+		tgbotapi.BotCommand{Command: commandBan, Description: translations["commandBan"]},
+		tgbotapi.BotCommand{Command: commandUnban, Description: translations["commandUnban"]},
+		tgbotapi.BotCommand{Command: commandAudit, Description: translations["commandAudit"]},
+		tgbotapi.BotCommand{Command: commandBroadcast, Description: translations["commandBroadcast"]},
+	)
+
+	for id := range tb.admins {
+		config := tgbotapi.NewSetMyCommandsWithScopeAndLanguage(tgbotapi.NewBotCommandScopeChat(id), languageCode, commands...)
+		if _, err := tb.Bot.Request(config); err != nil {
+			log.Printf("Error setting admin commands for %d: %s", id, err)
+		}
+	}
+}
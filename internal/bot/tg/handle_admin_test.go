@@ -0,0 +1,21 @@
+package tg
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseAdminIDs(t *testing.T) {
+	assert.Equal(t, map[int64]bool{1: true, 23: true}, parseAdminIDs(" 1, x,,23 "))
+	assert.Empty(t, parseAdminIDs(""))
+}
+
+func TestIsBanned(t *testing.T) {
+	tb := &TgBot{bans: map[string]bool{banTarget("@Spammer"): true, banTarget("42"): true}}
+
+	assert.True(t, tb.isBanned(&tgbotapi.User{ID: 1, UserName: "spammer"}))
+	assert.True(t, tb.isBanned(&tgbotapi.User{ID: 42}))
+	assert.False(t, tb.isBanned(&tgbotapi.User{ID: 2, UserName: "user"}))
+	assert.False(t, tb.isBanned(&tgbotapi.User{ID: 3}))
+}
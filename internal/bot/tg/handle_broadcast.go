@@ -0,0 +1,451 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"youtube_downloader/internal/bot/tg/send"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/ratelimit"
+	"youtube_downloader/internal/store"
+)
+
+const (
+	commandBroadcast = "broadcast"
+
+	broadcastCallbackPrefix = "broadcast:" // button's data: broadcast:<action>
+	broadcastActionSend     = "send"
+	broadcastActionCancel   = "cancel"
+	broadcastActionStop     = "stop"
+
+	// audiences of a broadcast, active and inactive ones are subscription statuses
+	audienceAll      = "all"
+	audienceActive   = tierActive
+	audienceInactive = tierInactive
+
+	broadcastStateSending = "sending"
+	broadcastStateDone    = "done"
+	broadcastStateStopped = "stopped"
+
+	broadcastProgressInterval = 5 * time.Second // the admin's chat gets an edit at most this often
+	chatSaveInterval          = time.Hour       // a chat is saved at most this often
+	audienceWorkers           = 8               // subscription statuses are requested in parallel by this many requests
+)
+
+// Telegram allows ~30 messages per second to different chats, the rest is left for replies to users
+var defaultBroadcastLimit = ratelimit.Limit{Requests: 20, Period: time.Second}
+
+// broadcaster keeps drafts of admins' broadcasts, the running broadcast and chats seen lately
+type broadcaster struct {
+	mu     sync.Mutex
+	drafts map[int64]*broadcastDraft // by admin id
+	cancel context.CancelFunc        // stops the running broadcast, nil if there is none
+	wg     sync.WaitGroup
+	seen   map[int64]time.Time // chats by the time they were saved
+}
+
+// broadcastDraft is a message an admin is going to broadcast
+type broadcastDraft struct {
+	composing  bool // the admin is asked for the message
+	fromChatID int64
+	messageID  int
+	audience   string
+	chats      []store.Chat // chats of the audience resolved when it was chosen
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{
+		drafts: make(map[int64]*broadcastDraft),
+		seen:   make(map[int64]time.Time),
+	}
+}
+
+// running return true if a broadcast is being sent
+func (b *broadcaster) running() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cancel != nil
+}
+
+// stop stops the running broadcast and waits for it
+func (b *broadcaster) stop() {
+	b.mu.Lock()
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// saveChat saves the private chat of the update, so it gets broadcasts
+func (tb *TgBot) saveChat(update tgbotapi.Update) {
+	message := update.Message
+	if message == nil || message.From == nil || !message.Chat.IsPrivate() {
+		return
+	}
+
+	b := tb.broadcasts
+	b.mu.Lock()
+	saved, ok := b.seen[message.Chat.ID]
+	if ok && time.Since(saved) < chatSaveInterval {
+		b.mu.Unlock()
+		return
+	}
+	b.seen[message.Chat.ID] = time.Now()
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	chat := &store.Chat{
+		ChatID:       message.Chat.ID,
+		UserID:       message.From.ID,
+		Username:     message.From.UserName,
+		LanguageCode: message.From.LanguageCode,
+	}
+	if err := tb.store.SaveChat(ctx, chat); err != nil {
+		log.Printf("can't save chat %d: %s", chat.ChatID, err)
+	}
+}
+
+// handleBroadcastCommand asks the admin for a message to broadcast
+func (tb *TgBot) handleBroadcastCommand(message *tgbotapi.Message, lang string) error {
+	if tb.broadcasts.running() {
+		return send.SendMessage(tb.Bot, message, tb.translations(lang)["broadcastRunning"])
+	}
+
+	tb.broadcasts.mu.Lock()
+	tb.broadcasts.drafts[message.From.ID] = &broadcastDraft{composing: true}
+	tb.broadcasts.mu.Unlock()
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, tb.translations(lang)["broadcastCompose"])
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(tb.translations(lang)["broadcastCancelButton"],
+			broadcastCallbackPrefix+broadcastActionCancel)))
+	_, err := tb.Bot.Send(msg)
+	return err
+}
+
+// takeBroadcastMessage takes the message as a broadcast if the admin is composing one and sends its preview.
+// It returns false if the message isn't a broadcast
+func (tb *TgBot) takeBroadcastMessage(message *tgbotapi.Message) bool {
+	tb.broadcasts.mu.Lock()
+	draft, ok := tb.broadcasts.drafts[message.From.ID]
+	if !ok || !draft.composing {
+		tb.broadcasts.mu.Unlock()
+		return false
+	}
+	draft.composing = false
+	draft.fromChatID = message.Chat.ID
+	draft.messageID = message.MessageID
+	tb.broadcasts.mu.Unlock()
+
+	lang := message.From.LanguageCode
+	translations := tb.translations(lang)
+
+	// the preview is a copy, so the admin sees the message exactly as users will
+	if _, err := tb.Bot.CopyMessage(tgbotapi.NewCopyMessage(message.Chat.ID, message.Chat.ID, message.MessageID)); err != nil {
+		log.Printf("can't send broadcast preview: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	chats, err := tb.broadcastAudience(ctx, audienceAll)
+	if err != nil {
+		log.Printf("can't get chats: %s", err)
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, locale.Plural(translations, "broadcastPreview", len(chats), nil))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			translations["broadcastAudienceAll"], broadcastCallbackPrefix+audienceAll)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			translations["broadcastAudienceActive"], broadcastCallbackPrefix+audienceActive)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			translations["broadcastAudienceInactive"], broadcastCallbackPrefix+audienceInactive)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			translations["broadcastCancelButton"], broadcastCallbackPrefix+broadcastActionCancel)),
+	)
+	if _, err := tb.Bot.Send(msg); err != nil {
+		log.Printf("can't send broadcast audiences: %s", err)
+	}
+	return true
+}
+
+// handleBroadcastCallback chooses the audience of the admin's draft, then sends, cancels or stops the broadcast
+func (tb *TgBot) handleBroadcastCallback(callbackQuery *tgbotapi.CallbackQuery) {
+	lang := callbackQuery.From.LanguageCode
+	translations := tb.translations(lang)
+	if !tb.isAdmin(callbackQuery.From.ID) {
+		send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, "")
+		return
+	}
+
+	chatID, messageID := callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID
+	action := strings.TrimPrefix(callbackQuery.Data, broadcastCallbackPrefix)
+
+	b := tb.broadcasts
+	b.mu.Lock()
+	draft, ok := b.drafts[callbackQuery.From.ID]
+	b.mu.Unlock()
+
+	var text string
+	var keyboard *tgbotapi.InlineKeyboardMarkup
+	switch action {
+	case audienceAll, audienceActive, audienceInactive:
+		if !ok || draft.composing {
+			text = translations["broadcastNoDraft"]
+			break
+		}
+		// statuses of users are checked for a while, so updates aren't held up
+		go tb.chooseAudience(draft, action, translations, chatID, messageID)
+		send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, "")
+		return
+	case broadcastActionSend:
+		if !ok || draft.composing || draft.audience == "" {
+			text = translations["broadcastNoDraft"]
+			break
+		}
+		if !tb.startBroadcast(callbackQuery.From, *draft, chatID, messageID) {
+			text = translations["broadcastRunning"]
+			break
+		}
+		b.mu.Lock()
+		delete(b.drafts, callbackQuery.From.ID)
+		b.mu.Unlock()
+		send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, "")
+		return
+	case broadcastActionCancel:
+		b.mu.Lock()
+		delete(b.drafts, callbackQuery.From.ID)
+		b.mu.Unlock()
+		text = translations["broadcastCanceled"]
+	case broadcastActionStop:
+		b.mu.Lock()
+		if b.cancel != nil {
+			b.cancel()
+		}
+		b.mu.Unlock()
+		// the final progress is shown by the broadcast
+		send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, "")
+		return
+	}
+
+	send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, "")
+	var err error
+	if keyboard != nil {
+		err = send.SendEditMessageWithKeyboard(tb.Bot, chatID, messageID, &text, keyboard)
+	} else {
+		err = send.SendEditMessage(tb.Bot, chatID, messageID, &text)
+	}
+	if err != nil {
+		log.Printf("can't edit broadcast message: %s", err)
+	}
+}
+
+// chooseAudience resolves chats of the audience for the draft and asks the admin to confirm sending to them
+func (tb *TgBot) chooseAudience(draft *broadcastDraft, audience string, translations map[string]string,
+	chatID int64, messageID int) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	chats, err := tb.broadcastAudience(ctx, audience)
+	cancel()
+	if err != nil {
+		log.Printf("can't get chats of audience %s: %s", audience, err)
+		text := locale.Text(translations, "adminError", locale.Params{"error": err.Error()})
+		send.SendEditMessage(tb.Bot, chatID, messageID, &text)
+		return
+	}
+
+	b := tb.broadcasts
+	b.mu.Lock()
+	draft.audience = audience
+	draft.chats = chats
+	b.mu.Unlock()
+
+	text := locale.Plural(translations, "broadcastConfirm", len(chats),
+		locale.Params{"audience": audienceText(audience, translations)})
+	confirm := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(translations["broadcastSendButton"], broadcastCallbackPrefix+broadcastActionSend),
+		tgbotapi.NewInlineKeyboardButtonData(translations["broadcastCancelButton"], broadcastCallbackPrefix+broadcastActionCancel),
+	))
+	if err := send.SendEditMessageWithKeyboard(tb.Bot, chatID, messageID, &text, &confirm); err != nil {
+		log.Printf("can't edit broadcast message: %s", err)
+	}
+}
+
+// startBroadcast starts sending the draft in the background, its progress is shown in the message of the admin.
+// It returns false if another broadcast is running
+func (tb *TgBot) startBroadcast(admin *tgbotapi.User, draft broadcastDraft, chatID int64, messageID int) bool {
+	b := tb.broadcasts
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancel != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		tb.runBroadcast(ctx, admin, draft, chatID, messageID)
+
+		b.mu.Lock()
+		b.cancel = nil
+		b.mu.Unlock()
+		cancel()
+	}()
+	return true
+}
+
+// broadcastAudience return known chats of the audience which aren't blocked.
+// Subscription statuses of users are checked at once by a few requests in parallel,
+// users whose status can't be got are inactive
+func (tb *TgBot) broadcastAudience(ctx context.Context, audience string) ([]store.Chat, error) {
+	chats, err := tb.store.Chats(ctx)
+	if err != nil || audience == audienceAll {
+		return chats, err
+	}
+
+	tiers := make([]string, len(chats))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < audienceWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				chat := chats[index]
+				tiers[index] = tb.userTier(ctx, &tgbotapi.User{ID: chat.UserID, UserName: chat.Username})
+			}
+		}()
+	}
+	for i := range chats {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var filtered []store.Chat
+	for i, chat := range chats {
+		if tiers[i] == audience {
+			filtered = append(filtered, chat)
+		}
+	}
+	return filtered, ctx.Err()
+}
+
+// runBroadcast copies the draft to every chat of its audience until ctx is done.
+// Chats of users who blocked the bot are marked, so they don't get next broadcasts
+func (tb *TgBot) runBroadcast(ctx context.Context, admin *tgbotapi.User, draft broadcastDraft, chatID int64, messageID int) {
+	translations := tb.translations(admin.LanguageCode)
+	audience := audienceText(draft.audience, translations)
+	chats := draft.chats
+
+	record := &store.Broadcast{
+		AdminID:    admin.ID,
+		FromChatID: draft.fromChatID,
+		MessageID:  draft.messageID,
+		Audience:   draft.audience,
+		State:      broadcastStateSending,
+		Total:      len(chats),
+	}
+	tb.saveBroadcast(record)
+	tb.recordAudit(admin, commandBroadcast, draft.audience, fmt.Sprintf("broadcast %d to %d chats", record.ID, len(chats)))
+	log.Printf("Broadcast %d to %s started: %d chats", record.ID, draft.audience, len(chats))
+
+	stop := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(translations["broadcastStopButton"], broadcastCallbackPrefix+broadcastActionStop)))
+	progress := func(key string, checked int) string {
+		return locale.Text(translations, key, locale.Params{"audience": audience, "checked": checked,
+			"total": record.Total, "sent": record.Sent, "failed": record.Failed, "blocked": record.Blocked})
+	}
+
+	throttle := ratelimit.NewThrottle(envLimit("BROADCAST_RATE_LIMIT", defaultBroadcastLimit))
+	reported := time.Now()
+	checked := 0
+	for _, chat := range chats {
+		if ctx.Err() != nil {
+			break
+		}
+		if time.Since(reported) >= broadcastProgressInterval {
+			reported = time.Now()
+			text := progress("broadcastProgress", checked)
+			send.SendEditMessageWithKeyboard(tb.Bot, chatID, messageID, &text, &stop)
+			tb.saveBroadcast(record)
+		}
+
+		checked++
+		err := tb.copyMessage(ctx, throttle, chat.ChatID, draft)
+		var apiErr *tgbotapi.Error
+		switch {
+		case err == nil:
+			record.Sent++
+		case errors.As(err, &apiErr) && apiErr.Code == 403:
+			record.Blocked++
+			if err := tb.store.SetChatBlocked(context.Background(), chat.ChatID); err != nil {
+				log.Printf("can't mark chat %d as blocked: %s", chat.ChatID, err)
+			}
+		case ctx.Err() != nil:
+			checked--
+		default:
+			record.Failed++
+			log.Printf("can't send broadcast %d to chat %d: %s", record.ID, chat.ChatID, err)
+		}
+	}
+
+	key := "broadcastDone"
+	record.State = broadcastStateDone
+	if ctx.Err() != nil {
+		key = "broadcastStopped"
+		record.State = broadcastStateStopped
+	}
+	tb.saveBroadcast(record)
+	text := progress(key, checked)
+	send.SendEditMessage(tb.Bot, chatID, messageID, &text)
+	log.Printf("Broadcast %d is %s: %d sent, %d failed, %d blocked", record.ID, record.State,
+		record.Sent, record.Failed, record.Blocked)
+}
+
+// copyMessage copies the draft to the chat, waiting for the throttle and retrying after flood errors
+func (tb *TgBot) copyMessage(ctx context.Context, throttle *ratelimit.Throttle, chatID int64, draft broadcastDraft) error {
+	for {
+		if err := throttle.Wait(ctx); err != nil {
+			return err
+		}
+		_, err := tb.Bot.CopyMessage(tgbotapi.NewCopyMessage(chatID, draft.fromChatID, draft.messageID))
+		var apiErr *tgbotapi.Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			log.Printf("broadcast is throttled by Telegram for %d s", apiErr.RetryAfter)
+			throttle.Pause(time.Duration(apiErr.RetryAfter) * time.Second)
+			continue
+		}
+		return err
+	}
+}
+
+// saveBroadcast saves the state and progress of the broadcast
+func (tb *TgBot) saveBroadcast(record *store.Broadcast) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tb.store.SaveBroadcast(ctx, record); err != nil {
+		log.Printf("can't save broadcast %d: %s", record.ID, err)
+	}
+}
+
+// audienceText return the localized name of the audience
+func audienceText(audience string, translations map[string]string) string {
+	switch audience {
+	case audienceActive:
+		return translations["broadcastAudienceActive"]
+	case audienceInactive:
+		return translations["broadcastAudienceInactive"]
+	default:
+		return translations["broadcastAudienceAll"]
+	}
+}
@@ -5,27 +5,44 @@ import (
 	"log"
 	"strings"
 	. "youtube_downloader/internal/bot/tg/handler"
+	"youtube_downloader/internal/bot/tg/handler/youtube"
 	"youtube_downloader/internal/bot/tg/send"
 )
 
-// handleCallbackQuery gets url from Bot's message with a replying link,
-// then handle a link by its type: video (stream), playlist
+// handleCallbackQuery dispatches the pressed button by its data:
+// queue and broadcast controls, payments, and buttons of keyboards for links
 func (tb *TgBot) handleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery) {
 	data := callbackQuery.Data
 	parts := strings.Split(data, ",")
 	data = parts[0]
 	lang := callbackQuery.From.LanguageCode
 
+	if !isKeyboardOwner(callbackQuery) {
+		if err := send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, tb.translations(lang)["notYourKeyboard"]); err != nil {
+			log.Printf("can't answer callback query: %s", err)
+		}
+		return
+	}
+
 	switch {
+	case strings.HasPrefix(callbackQuery.Data, queueCallbackPrefix):
+		tb.handleQueueCallback(callbackQuery)
+	case strings.HasPrefix(callbackQuery.Data, broadcastCallbackPrefix):
+		tb.handleBroadcastCallback(callbackQuery)
+	case strings.HasPrefix(callbackQuery.Data, settingsCallbackPrefix):
+		tb.handleSettingsCallback(callbackQuery)
+	case strings.HasPrefix(callbackQuery.Data, languageCallbackPrefix):
+		tb.handleLanguageCallback(callbackQuery)
 	case strings.HasPrefix(data, "pay_"):
 		subscriptionType := strings.TrimPrefix(data, "pay_")
-		tb.processPayment(callbackQuery.Message, subscriptionType)
-	case isYoutubeLink(data):
-		tr := tb.translations[lang]
+		tb.processPayment(callbackQuery.Message, subscriptionType, lang)
+	// buttons sent before tokens were used still carry a link, the handler tells that they're expired
+	case strings.HasPrefix(callbackQuery.Data, youtube.CallbackPrefix), isYoutubeLink(data):
+		tr := tb.translations(lang)
 		tb.handlers[YoutubeHandler].HandleCallbackQuery(callbackQuery, tb.Bot, tb.Client, &tr)
 	default:
 		log.Printf("handleCallbackQuery get default case with %s link", data)
-		somethingWentWrong := tb.translations[lang]["somethingWentWrong"]
+		somethingWentWrong := tb.translations(lang)["somethingWentWrong"]
 		send.SendReplyMessage(tb.Bot, callbackQuery.Message, &somethingWentWrong)
 	}
 }
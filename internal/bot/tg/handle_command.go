@@ -2,13 +2,15 @@ package tg
 
 import (
 	"context"
-	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
 	"log"
 	"os"
 	"time"
+	"youtube_downloader/internal/bot/tg/handler"
+	"youtube_downloader/internal/bot/tg/handler/youtube"
 	"youtube_downloader/internal/bot/tg/send"
+	"youtube_downloader/internal/locale"
 )
 
 const (
@@ -25,6 +27,7 @@ const (
 // handleCommand handles supported commands
 func (tb *TgBot) handleCommand(message *tgbotapi.Message) {
 	lang := message.From.LanguageCode
+	group := isGroup(message.Chat)
 	switch message.Command() {
 	case commandStart:
 		tb.handleStartCommand(message, lang)
@@ -34,8 +37,22 @@ func (tb *TgBot) handleCommand(message *tgbotapi.Message) {
 		tb.handlePayCommand(message)
 	case commandStatus:
 		tb.UserStatus(message, lang)
+	case commandQueue, commandJobs:
+		tb.handleQueueCommand(message, lang)
+	case commandAuto:
+		tb.handleAutoCommand(message, lang)
+	case commandSettings:
+		tb.handleSettingsCommand(message, lang)
+	case commandLanguage:
+		tb.handleLanguageCommand(message, lang)
+	case commandStats, commandUser, commandGrant, commandResetTraffic, commandBan, commandUnban, commandAudit,
+		commandBroadcast:
+		tb.handleAdminCommand(message, lang)
 	default:
-		tb.handleDefaultCommand(message, lang)
+		// commands of other bots in the group aren't answered
+		if !group {
+			tb.handleDefaultCommand(message, lang)
+		}
 	}
 }
 
@@ -48,55 +65,59 @@ func (tb *TgBot) handlePayCommand(message *tgbotapi.Message) {
 		return
 	}
 
-	tb.processPayment(message, subscriptionType)
+	tb.processPayment(message, subscriptionType, message.From.LanguageCode)
 }
 
 // sendPayOptions sends buttons with subscription options to the user
 func (tb *TgBot) sendPayOptions(message *tgbotapi.Message) {
 	lang := message.From.LanguageCode
 	buttons := []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData(tb.translations[lang]["monthlyButton"], payMonth),
-		tgbotapi.NewInlineKeyboardButtonData(tb.translations[lang]["yearlyButton"], payYear),
-		tgbotapi.NewInlineKeyboardButtonData(tb.translations[lang]["lifetimeButton"], payLifetime),
+		tgbotapi.NewInlineKeyboardButtonData(tb.translations(lang)["monthlyButton"], payMonth),
+		tgbotapi.NewInlineKeyboardButtonData(tb.translations(lang)["yearlyButton"], payYear),
+		tgbotapi.NewInlineKeyboardButtonData(tb.translations(lang)["lifetimeButton"], payLifetime),
 	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons)
-	msg := tgbotapi.NewMessage(message.Chat.ID, tb.translations[lang]["chooseSubscriptionPlan"])
+	msg := tgbotapi.NewMessage(message.Chat.ID, tb.translations(lang)["chooseSubscriptionPlan"])
 	msg.ReplyMarkup = keyboard
+	if isGroup(message.Chat) {
+		msg.ReplyToMessageID = message.MessageID
+	}
 
 	if _, err := tb.Bot.Send(msg); err != nil {
 		log.Println("Error sending payment options:", err)
 	}
 }
 
-// processPayment processes the payment based on the selected subscription type
-func (tb *TgBot) processPayment(message *tgbotapi.Message, subscriptionType string) {
-	lang := "en"
+// processPayment processes the payment based on the selected subscription type.
+// The invoice is sent to the chat of the message in lang of the user who asked for it,
+// since the message is the bot's one when a button is pressed
+func (tb *TgBot) processPayment(message *tgbotapi.Message, subscriptionType, lang string) {
 	subscriptions := map[string]struct {
 		Title       string
 		Description string
 		Amount      int
 	}{
 		"month": {
-			Title:       tb.translations[lang]["monthlyTitle"],
-			Description: tb.translations[lang]["monthlyDescription"],
+			Title:       tb.translations(lang)["monthlyTitle"],
+			Description: tb.translations(lang)["monthlyDescription"],
 			Amount:      10000,
 		},
 		"year": {
-			Title:       tb.translations[lang]["yearlyTitle"],
-			Description: tb.translations[lang]["yearlyDescription"],
+			Title:       tb.translations(lang)["yearlyTitle"],
+			Description: tb.translations(lang)["yearlyDescription"],
 			Amount:      100000,
 		},
 		"lifetime": {
-			Title:       tb.translations[lang]["lifetimeTitle"],
-			Description: tb.translations[lang]["lifetimeDescription"],
+			Title:       tb.translations(lang)["lifetimeTitle"],
+			Description: tb.translations(lang)["lifetimeDescription"],
 			Amount:      200000,
 		},
 	}
 
 	subscription, exists := subscriptions[subscriptionType]
 	if !exists {
-		send.SendMessage(tb.Bot, message, tb.translations[lang]["invalidSubscriptionType"])
+		send.SendMessage(tb.Bot, message, tb.translations(lang)["invalidSubscriptionType"])
 		return
 	}
 
@@ -148,6 +169,7 @@ func (tb *TgBot) handleSuccessfulPayment(message *tgbotapi.Message) {
 
 	lang := message.From.LanguageCode
 	payload := message.SuccessfulPayment.InvoicePayload
+	tb.forgetTier(message.From.ID) // the user gets the limits of subscribers right away
 	user.Subscription.SubscriptionStatus = "active"
 
 	now := time.Now() // Get current time
@@ -163,11 +185,11 @@ func (tb *TgBot) handleSuccessfulPayment(message *tgbotapi.Message) {
 	err = tb.Client.UpdateSubscription(ctx, user)
 	if err != nil {
 		log.Printf("Error updating user subscription: %s", err.Error())
-		send.SendMessage(tb.Bot, message, tb.translations[lang]["errorUpdatingSubscription"])
+		send.SendMessage(tb.Bot, message, tb.translations(lang)["errorUpdatingSubscription"])
 		return
 	}
 
-	send.SendMessage(tb.Bot, message, tb.translations[lang]["thankYouForPayment"])
+	send.SendMessage(tb.Bot, message, tb.translations(lang)["thankYouForPayment"])
 }
 
 func addDurationToTime(t time.Time, duration string) time.Time {
@@ -182,19 +204,25 @@ func addDurationToTime(t time.Time, duration string) time.Time {
 	return t
 }
 
-// handleStartCommand sends a message with startMessage text
+// handleStartCommand sends a message with startMessage text.
+// If the bot was started by a deep link from an inline result, it downloads the chosen preset instead
 func (tb *TgBot) handleStartCommand(message *tgbotapi.Message, lang string) error {
-	return send.SendMessage(tb.Bot, message, tb.translations[lang]["startMessage"])
+	if parameter := message.CommandArguments(); youtube.IsPresetStartParameter(parameter) {
+		translations := tb.translations(lang)
+		tb.handlers[handler.YoutubeHandler].HandlePreset(message, parameter, tb.Bot, tb.Client, &translations)
+		return nil
+	}
+	return send.SendMessage(tb.Bot, message, tb.translations(lang)["startMessage"])
 }
 
 // handleHelpCommand sends a message with helpMessage text
 func (tb *TgBot) handleHelpCommand(message *tgbotapi.Message, lang string) error {
-	return send.SendMessage(tb.Bot, message, tb.translations[lang]["helpMessage"])
+	return send.SendMessage(tb.Bot, message, tb.translations(lang)["helpMessage"])
 }
 
 // handleDefaultCommand sends a message with defaultMessage text
 func (tb *TgBot) handleDefaultCommand(message *tgbotapi.Message, lang string) error {
-	return send.SendMessage(tb.Bot, message, tb.translations[lang]["defaultMessage"])
+	return send.SendMessage(tb.Bot, message, tb.translations(lang)["defaultMessage"])
 }
 
 // UserStatus send user's subscription status and subscription expiration date if active
@@ -205,15 +233,19 @@ func (tb *TgBot) UserStatus(message *tgbotapi.Message, lang string) error {
 	user, err := tb.Client.GetUser(ctx, message.From.UserName)
 	if err != nil || user == nil {
 		log.Println("can't get user: " + message.From.UserName)
-		return send.SendMessage(tb.Bot, message, tb.translations[lang]["errorFindStatus"])
+		return send.SendMessage(tb.Bot, message, tb.translations(lang)["errorFindStatus"])
 	}
 
-	statusText := tb.translations[lang]["userStatus"]
-	expireText := tb.translations[lang]["expireSubscription"]
+	translations := tb.translations(lang)
+	status := user.Subscription.SubscriptionStatus
+	if translated, ok := translations["subscriptionStatus_"+status]; ok {
+		status = translated
+	}
 
-	text := fmt.Sprintf("%s %s.", statusText, user.Subscription.SubscriptionStatus)
+	text := locale.Text(translations, "userStatus", locale.Params{"status": status})
 	if user.Subscription.SubscriptionStatus == "active" {
-		text += fmt.Sprintf(" %s %s", expireText, user.Subscription.EndSubscription.Format("2006-01-02"))
+		text += " " + locale.Text(translations, "expireSubscription",
+			locale.Params{"date": user.Subscription.EndSubscription.Format("2006-01-02")})
 	}
 
 	return send.SendMessage(tb.Bot, message, text)
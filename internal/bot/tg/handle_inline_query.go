@@ -0,0 +1,40 @@
+package tg
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"strings"
+	. "youtube_downloader/internal/bot/tg/handler"
+)
+
+const inlineCacheTime = 60 // secs
+
+// handleInlineQuery answers "@bot <link>" queries with downloadable results for the link
+func (tb *TgBot) handleInlineQuery(inlineQuery *tgbotapi.InlineQuery) {
+	log.Printf("[%s] inline: %s", inlineQuery.From.UserName, inlineQuery.Query)
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: inlineQuery.ID,
+		CacheTime:     inlineCacheTime,
+		IsPersonal:    true,
+		Results:       []interface{}{},
+	}
+
+	query := strings.TrimSpace(inlineQuery.Query)
+	if query != "" {
+		link := normalizeLink(query)
+		if isYoutubeLink(link) {
+			translations := tb.translations(inlineQuery.From.LanguageCode)
+			results, err := tb.handlers[YoutubeHandler].HandleInlineQuery(inlineQuery, link, tb.Bot, &translations)
+			if err != nil {
+				log.Printf("HandleInlineQuery error: %v", err)
+			} else {
+				answer.Results = results
+			}
+		}
+	}
+
+	if _, err := tb.Bot.Request(answer); err != nil {
+		log.Println("Error answering inline query:", err)
+	}
+}
@@ -0,0 +1,118 @@
+package tg
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"youtube_downloader/internal/bot/tg/handler/youtube"
+	"youtube_downloader/internal/bot/tg/send"
+	"youtube_downloader/internal/locale"
+)
+
+const (
+	commandLanguage = "language"
+
+	languageCallbackPrefix = "language:" // button's data: language:<language>
+)
+
+// languageCache keeps languages chosen by users, so the store isn't requested on every update
+type languageCache struct {
+	mu        sync.Mutex
+	languages map[int64]string // an empty language means the user didn't choose one
+}
+
+// setLanguage replaces the language code of the user who sent the update by the chosen language
+// or by the supported one closest to the language of the user's Telegram
+func (tb *TgBot) setLanguage(update tgbotapi.Update) {
+	var user *tgbotapi.User
+	switch {
+	case update.Message != nil:
+		user = update.Message.From
+	case update.CallbackQuery != nil:
+		user = update.CallbackQuery.From
+	case update.InlineQuery != nil:
+		user = update.InlineQuery.From
+	}
+	if user == nil {
+		return
+	}
+	if chosen := tb.chosenLanguage(user.ID); chosen != "" {
+		user.LanguageCode = chosen
+		return
+	}
+	user.LanguageCode = tb.localizer.Language(user.LanguageCode)
+}
+
+// chosenLanguage return the language chosen by the user with /language or an empty string
+func (tb *TgBot) chosenLanguage(userID int64) string {
+	tb.languages.mu.Lock()
+	defer tb.languages.mu.Unlock()
+
+	chosen, ok := tb.languages.languages[userID]
+	if !ok {
+		if tb.store != nil {
+			chosen = youtube.LoadSettings(tb.store, userID).Language
+		}
+		tb.languages.languages[userID] = chosen
+	}
+	if !tb.localizer.Supports(chosen) {
+		// the translation file of the language was removed
+		return ""
+	}
+	return chosen
+}
+
+// handleLanguageCommand sends the user a button for every supported language
+func (tb *TgBot) handleLanguageCommand(message *tgbotapi.Message, lang string) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, language := range tb.localizer.Languages() {
+		name := tb.translations(language)["languageName"]
+		if language == lang {
+			name = "✅ " + name
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(name, languageCallbackPrefix+language)))
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, tb.translations(lang)["chooseLanguage"])
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if isGroup(message.Chat) {
+		msg.ReplyToMessageID = message.MessageID
+	}
+	if _, err := tb.Bot.Send(msg); err != nil {
+		log.Printf("can't send languages: %s", err)
+	}
+}
+
+// handleLanguageCallback saves the language chosen by the button of the /language message
+func (tb *TgBot) handleLanguageCallback(callbackQuery *tgbotapi.CallbackQuery) {
+	language := strings.TrimPrefix(callbackQuery.Data, languageCallbackPrefix)
+	if !tb.localizer.Supports(language) {
+		send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, tb.translations(callbackQuery.From.LanguageCode)["somethingWentWrong"])
+		return
+	}
+
+	settings := youtube.LoadSettings(tb.store, callbackQuery.From.ID)
+	settings.Language = language
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tb.store.SaveSettings(ctx, settings); err != nil {
+		log.Printf("can't save language of user %d: %s", settings.UserID, err)
+		send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, tb.translations(callbackQuery.From.LanguageCode)["somethingWentWrong"])
+		return
+	}
+
+	tb.languages.mu.Lock()
+	tb.languages.languages[callbackQuery.From.ID] = language
+	tb.languages.mu.Unlock()
+
+	translations := tb.translations(language)
+	send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, "")
+	text := locale.Text(translations, "languageChanged", locale.Params{"language": translations["languageName"]})
+	if err := send.SendEditMessage(tb.Bot, callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, &text); err != nil {
+		log.Printf("can't edit languages: %s", err)
+	}
+}
@@ -0,0 +1,35 @@
+package tg
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"youtube_downloader/internal/locale"
+)
+
+func TestSetLanguage(t *testing.T) {
+	tb := &TgBot{
+		localizer: locale.New(map[string]map[string]string{"en": {}, "ru": {}}, "en"),
+		languages: &languageCache{languages: map[int64]string{1: "ru"}},
+	}
+
+	chosen := tgbotapi.Update{Message: &tgbotapi.Message{From: &tgbotapi.User{ID: 1, LanguageCode: "en"}}}
+	tb.setLanguage(chosen)
+	assert.Equal(t, "ru", chosen.Message.From.LanguageCode)
+
+	unsupported := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: &tgbotapi.User{ID: 2, LanguageCode: "de"}}}
+	tb.setLanguage(unsupported)
+	assert.Equal(t, "en", unsupported.CallbackQuery.From.LanguageCode)
+
+	regional := tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{From: &tgbotapi.User{ID: 3, LanguageCode: "ru-RU"}}}
+	tb.setLanguage(regional)
+	assert.Equal(t, "ru", regional.InlineQuery.From.LanguageCode)
+}
+
+func TestLocales(t *testing.T) {
+	localizer, err := locale.Load("../../../cmd/locales", locale.DefaultLanguage)
+	if assert.NoError(t, err) {
+		assert.Empty(t, localizer.Missing())
+		assert.Contains(t, locale.Plural(localizer.Translations("ru"), "queuedBatchNotification", 3, nil), "3 файла")
+	}
+}
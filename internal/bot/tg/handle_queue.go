@@ -0,0 +1,119 @@
+package tg
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"math"
+	"strings"
+	"time"
+	"youtube_downloader/internal/bot/tg/handler/youtube"
+	"youtube_downloader/internal/bot/tg/send"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/queue"
+)
+
+const (
+	commandQueue = "queue"
+	commandJobs  = "jobs" // an alias of /queue
+
+	queueCallbackPrefix = "queue:" // button's data: queue:<action>:<job id>
+	queueActionCancel   = "cancel"
+	queueActionUp       = "up"
+	queueActionRefresh  = "refresh"
+
+	maxJobTitleLength = 50
+)
+
+// handleQueueCommand sends the user a list of the user's downloads with buttons to manage them
+func (tb *TgBot) handleQueueCommand(message *tgbotapi.Message, lang string) {
+	text, keyboard := tb.queueMessage(message.From.ID, lang)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyMarkup = keyboard
+	if isGroup(message.Chat) {
+		msg.ReplyToMessageID = message.MessageID
+	}
+	if _, err := tb.Bot.Send(msg); err != nil {
+		log.Printf("can't send queue: %s", err)
+	}
+}
+
+// handleQueueCallback cancels or moves up a job by the button of the /queue message and updates the message
+func (tb *TgBot) handleQueueCallback(callbackQuery *tgbotapi.CallbackQuery) {
+	lang := callbackQuery.From.LanguageCode
+	action, id, _ := strings.Cut(strings.TrimPrefix(callbackQuery.Data, queueCallbackPrefix), ":")
+
+	ok := true
+	switch action {
+	case queueActionCancel:
+		ok = tb.jobs.Cancel(callbackQuery.From.ID, id)
+	case queueActionUp:
+		ok = tb.jobs.MoveUp(callbackQuery.From.ID, id)
+	}
+	if !ok {
+		if err := send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, tb.translations(lang)["jobNotFound"]); err != nil {
+			log.Printf("can't answer callback query: %s", err)
+		}
+	}
+
+	text, keyboard := tb.queueMessage(callbackQuery.From.ID, lang)
+	err := send.SendEditMessageWithKeyboard(tb.Bot, callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, &text, &keyboard)
+	if err != nil {
+		log.Printf("can't edit queue: %s", err)
+	}
+}
+
+// queueMessage return a text with the user's running and queued jobs and a keyboard to cancel or move them up
+func (tb *TgBot) queueMessage(userID int64, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	translations := tb.translations(lang)
+	jobs := tb.jobs.UserJobs(userID)
+
+	refresh := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+		translations["queueRefreshButton"], queueCallbackPrefix+queueActionRefresh))
+	if len(jobs) == 0 {
+		return translations["queueEmpty"], tgbotapi.NewInlineKeyboardMarkup(refresh)
+	}
+
+	var text strings.Builder
+	text.WriteString(translations["queueTitle"])
+	keyboard := tgbotapi.NewInlineKeyboardMarkup()
+	firstQueued := true
+	for i, job := range jobs {
+		number := i + 1
+		fmt.Fprintf(&text, "\n\n%d. %s\n%s", number, youtube.TruncateTitle(job.Title, maxJobTitleLength), jobStateText(job, translations))
+
+		row := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			locale.Text(translations, "queueCancelButton", locale.Params{"number": number}), queueCallbackPrefix+queueActionCancel+":"+job.ID))
+		if job.State == queue.StateQueued {
+			// the user's first queued job can't be moved up
+			if !firstQueued {
+				row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+					locale.Text(translations, "queueMoveUpButton", locale.Params{"number": number}), queueCallbackPrefix+queueActionUp+":"+job.ID))
+			}
+			firstQueued = false
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, refresh)
+	return text.String(), keyboard
+}
+
+// jobStateText return the job's state with its progress, or its position and start time if it's queued
+func jobStateText(job queue.JobInfo, translations map[string]string) string {
+	switch job.State {
+	case queue.StateQueued:
+		if job.Wait < time.Minute {
+			return locale.Text(translations, "jobStateQueuedSoon", locale.Params{"position": job.Position})
+		}
+		return locale.Text(translations, "jobStateQueued",
+			locale.Params{"position": job.Position, "minutes": int(math.Ceil(job.Wait.Minutes()))})
+	case queue.StateDownloading:
+		return locale.Text(translations, "jobStateDownloading", locale.Params{"progress": fmt.Sprintf("%.0f", job.Progress)})
+	case queue.StateMerging:
+		return translations["jobStateMerging"]
+	case queue.StateUploading:
+		return translations["jobStateUploading"]
+	default:
+		return string(job.State)
+	}
+}
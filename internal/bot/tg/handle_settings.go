@@ -0,0 +1,119 @@
+package tg
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"youtube_downloader/internal/bot/tg/handler/youtube"
+	"youtube_downloader/internal/bot/tg/send"
+	"youtube_downloader/internal/store"
+)
+
+const (
+	commandSettings = "settings"
+
+	settingsCallbackPrefix = "settings:" // button's data: settings:<setting>, a press switches it to the next option
+	settingMode            = "mode"
+	settingResolution      = "resolution"
+	settingCodec           = "codec"
+	settingDocument        = "document"
+	settingCaption         = "caption"
+)
+
+// optionKeys are translation keys of options of settings
+var optionKeys = map[string]string{
+	youtube.ModeAsk:          "settingsModeAsk",
+	youtube.ModeAudio:        "settingsModeAudio",
+	youtube.ModeVideo:        "settingsModeVideo",
+	youtube.ModeFit:          "settingsModeFit",
+	youtube.CodecAAC:         "settingsCodecAAC",
+	youtube.CodecOpus:        "settingsCodecOpus",
+	youtube.CaptionFileName:  "settingsCaptionFileName",
+	youtube.CaptionTitle:     "settingsCaptionTitle",
+	youtube.CaptionTitleLink: "settingsCaptionTitleLink",
+	youtube.CaptionNone:      "settingsCaptionNone",
+}
+
+// handleSettingsCommand sends the user a menu of the user's settings
+func (tb *TgBot) handleSettingsCommand(message *tgbotapi.Message, lang string) {
+	text, keyboard := tb.settingsMessage(youtube.LoadSettings(tb.store, message.From.ID), lang)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyMarkup = keyboard
+	if isGroup(message.Chat) {
+		msg.ReplyToMessageID = message.MessageID
+	}
+	if _, err := tb.Bot.Send(msg); err != nil {
+		log.Printf("can't send settings: %s", err)
+	}
+}
+
+// handleSettingsCallback switches the setting by the button of the /settings message to the next option and updates the message
+func (tb *TgBot) handleSettingsCallback(callbackQuery *tgbotapi.CallbackQuery) {
+	lang := callbackQuery.From.LanguageCode
+	settings := youtube.LoadSettings(tb.store, callbackQuery.From.ID)
+
+	switch strings.TrimPrefix(callbackQuery.Data, settingsCallbackPrefix) {
+	case settingMode:
+		settings.Mode = nextOption(youtube.Modes, settings.Mode)
+	case settingResolution:
+		settings.MaxHeight = nextOption(youtube.MaxHeights, settings.MaxHeight)
+	case settingCodec:
+		settings.AudioCodec = nextOption(youtube.AudioCodecs, settings.AudioCodec)
+	case settingDocument:
+		settings.AsDocument = !settings.AsDocument
+	case settingCaption:
+		settings.Caption = nextOption(youtube.Captions, settings.Caption)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tb.store.SaveSettings(ctx, settings); err != nil {
+		log.Printf("can't save settings of user %d: %s", settings.UserID, err)
+		send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, tb.translations(lang)["somethingWentWrong"])
+		return
+	}
+	send.SendCallbackAnswer(tb.Bot, callbackQuery.ID, "")
+
+	text, keyboard := tb.settingsMessage(settings, lang)
+	err := send.SendEditMessageWithKeyboard(tb.Bot, callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID, &text, &keyboard)
+	if err != nil {
+		log.Printf("can't edit settings: %s", err)
+	}
+}
+
+// settingsMessage return the text and the keyboard of the /settings message, every button shows the current option
+func (tb *TgBot) settingsMessage(settings *store.Settings, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	translations := tb.translations(lang)
+
+	resolution := translations["settingsResolutionAny"]
+	if settings.MaxHeight > 0 {
+		resolution = fmt.Sprintf("%dp", settings.MaxHeight)
+	}
+	document := translations["settingsOff"]
+	if settings.AsDocument {
+		document = translations["settingsOn"]
+	}
+
+	row := func(name string, option string, setting string) []tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%s: %s", translations[name], option), settingsCallbackPrefix+setting))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		row("settingsMode", translations[optionKeys[settings.Mode]], settingMode),
+		row("settingsResolution", resolution, settingResolution),
+		row("settingsCodec", translations[optionKeys[settings.AudioCodec]], settingCodec),
+		row("settingsDocument", document, settingDocument),
+		row("settingsCaption", translations[optionKeys[settings.Caption]], settingCaption),
+	)
+	return translations["settingsTitle"], keyboard
+}
+
+// nextOption return the option after the current one, the first option follows the last and an unknown one
+func nextOption[T comparable](options []T, current T) T {
+	i := slices.Index(options, current)
+	return options[(i+1)%len(options)]
+}
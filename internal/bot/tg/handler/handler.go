@@ -4,6 +4,8 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"youtube_downloader/internal/bot/tg/handler/youtube"
 	database_client "youtube_downloader/internal/database-client"
+	"youtube_downloader/internal/queue"
+	"youtube_downloader/internal/store"
 )
 
 type HandlerType int
@@ -17,14 +19,18 @@ var SupportedHandlers = []HandlerType{
 }
 
 type Handler interface {
-	HandleMessage(message *tgbotapi.Message) (*tgbotapi.InlineKeyboardMarkup, error)
+	HandleMessage(message *tgbotapi.Message, link string, bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) (*tgbotapi.InlineKeyboardMarkup, error)
 	HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string)
+	HandleInlineQuery(inlineQuery *tgbotapi.InlineQuery, link string, bot *tgbotapi.BotAPI, translations *map[string]string) ([]interface{}, error)
+	HandlePreset(message *tgbotapi.Message, parameter string, bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string)
+	ResumeJob(record store.Job, bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) error
 }
 
-func CreateHandler(handlerType HandlerType) Handler {
+// CreateHandler return a handler of the type which runs downloads in the queue and saves them in the store
+func CreateHandler(handlerType HandlerType, jobs *queue.Queue, st *store.Store) Handler {
 	switch handlerType {
 	case YoutubeHandler:
-		return youtube.NewYoutubeHandler()
+		return youtube.NewYoutubeHandler(jobs, st)
 	default:
 		return nil
 	}
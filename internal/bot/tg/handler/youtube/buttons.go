@@ -0,0 +1,89 @@
+package youtube
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+	"time"
+	"youtube_downloader/internal/store"
+)
+
+const (
+	CallbackPrefix = "t:"               // button's data is the prefix and a token of the payload in the store
+	callbackTTL    = 7 * 24 * time.Hour // buttons of older keyboards are expired
+	tokenSize      = 9                  // bytes, a token is 12 chars long
+
+	actionFormat        = "format"
+	actionRecord        = "record"
+	actionPlaylistAll   = "playlistAll"
+	actionChannelLast   = "channelLast"
+	actionPage          = "page"
+	actionPageIndicator = "pageIndicator"
+	actionPlaylistVideo = "playlistVideo"
+	actionMusicAlbum    = "musicAlbum"
+	actionSelect        = "select"
+	actionSelectRange   = "selectRange"
+	actionSelectInvert  = "selectInvert"
+	actionSelected      = "selected" // downloads the selected entries
+)
+
+var errNoStore = errors.New("no store for buttons' payloads")
+
+// buttons creates keyboard buttons which carry only a token, their payloads are saved to the store at once.
+// Telegram limits button's data to 64 bytes, so a long link doesn't fit into it
+type buttons struct {
+	requesterID  int64
+	translations map[string]string // texts of buttons are in the language of the requester
+	callbacks    []*store.Callback
+}
+
+// newButtons return buttons for a keyboard sent to the user
+func newButtons(requesterID int64, translations *map[string]string) *buttons {
+	return &buttons{requesterID: requesterID, translations: *translations}
+}
+
+// button return a button with the text which is pressed with the payload
+func (b *buttons) button(text string, payload store.Callback) tgbotapi.InlineKeyboardButton {
+	payload.Token = newToken()
+	payload.RequesterID = b.requesterID
+	payload.ExpiresAt = time.Now().Add(callbackTTL)
+	b.callbacks = append(b.callbacks, &payload)
+	return tgbotapi.NewInlineKeyboardButtonData(text, CallbackPrefix+payload.Token)
+}
+
+// newToken return a random url-safe token
+func newToken() string {
+	token := make([]byte, tokenSize)
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// saveButtons saves payloads of the buttons, the keyboard can be sent only after that
+func (yh *YoutubeHandler) saveButtons(b *buttons) error {
+	if yh.Store == nil {
+		return errNoStore
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return yh.Store.SaveCallbacks(ctx, b.callbacks)
+}
+
+// callback return the payload of the pressed button, store.ErrCallbackNotFound for unknown data
+func (yh *YoutubeHandler) callback(data string) (*store.Callback, error) {
+	token, ok := strings.CutPrefix(data, CallbackPrefix)
+	if !ok {
+		// the button is from a keyboard sent before payloads were saved
+		return nil, store.ErrCallbackNotFound
+	}
+	if yh.Store == nil {
+		return nil, errNoStore
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return yh.Store.Callback(ctx, token)
+}
@@ -2,6 +2,7 @@ package youtube
 
 import (
 	"context"
+	"errors"
 	"github.com/YuarenArt/tg-users-database/pkg/db"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/kkdai/youtube/v2"
@@ -13,55 +14,61 @@ import (
 	"youtube_downloader/internal/bot/tg/send"
 	database_client "youtube_downloader/internal/database-client"
 	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+	"youtube_downloader/internal/store"
 )
 
-// TODO rework a way to get data for downloading
-
-// TODO refactore indexes for data
-
-// HandleCallbackQuery gets url from Bot's message with a replying link,
-// then handle a link by its type: video (stream), playlist
+// HandleCallbackQuery loads the payload of the pressed button by its token,
+// then handle it by its action: a format of video, a recording of stream, playlist, album
 func (yh *YoutubeHandler) HandleCallbackQuery(callbackQuery *tgbotapi.CallbackQuery, bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) {
 
-	// gets URL from a data
-	text := callbackQuery.Data
-	parts := strings.Split(text, ",")
-	URL := parts[0]
+	callback, err := yh.callback(callbackQuery.Data)
+	if err != nil {
+		text := (*translations)["keyboardExpired"]
+		if !errors.Is(err, store.ErrCallbackNotFound) {
+			log.Printf("can't get callback %s: %s", callbackQuery.Data, err)
+			text = (*translations)["somethingWentWrong"]
+		}
+		send.SendReplyMessage(bot, callbackQuery.Message, &text)
+		return
+	}
+	if callback.RequesterID != 0 && callback.RequesterID != callbackQuery.From.ID {
+		if err := send.SendCallbackAnswer(bot, callbackQuery.ID, (*translations)["notYourKeyboard"]); err != nil {
+			log.Printf("can't answer callback query: %s", err)
+		}
+		return
+	}
 
-	switch {
-	// TODO fix that need to obtain link for handling playlist Button
-	case strings.HasPrefix(URL, "https://youtube.com/playlist?") || URL == youtubeCheckPlaylist:
-		yh.HandleCallbackQueryWithPlaylist(callbackQuery, bot, client, translations)
+	switch callback.Action {
+	case actionPageIndicator:
+		send.SendCallbackAnswer(bot, callbackQuery.ID, "")
+	case actionFormat:
+		yh.HandleCallbackQueryWithFormats(callbackQuery, callback, bot, client, translations)
+	case actionRecord:
+		yh.HandleCallbackQueryWithRecording(callbackQuery, callback, bot, client, translations)
+	case actionMusicAlbum:
+		yh.HandleCallbackQueryWithMusic(callbackQuery, callback, bot, client, translations)
+	case actionPlaylistVideo:
+		yh.processSingleVideo(bot, callbackQuery, callback, translations)
 	default:
-		yh.HandleCallbackQueryWithFormats(callbackQuery, bot, client, translations)
+		yh.HandleCallbackQueryWithPlaylist(callbackQuery, callback, bot, client, translations)
 	}
 }
 
-// HandleCallbackQueryWithFormats gets a link on video by callbackQuery.Message.Text,
-// gets ItagNo by callbackQuery.Data to find a correct format,
+// HandleCallbackQueryWithFormats gets a link on video and ItagNo from the button's payload,
 // gets possible formats by videoURL,
 // and finally gets the format selected by the user.
 // then download it with format
-func (yh *YoutubeHandler) HandleCallbackQueryWithFormats(callbackQuery *tgbotapi.CallbackQuery, bot *tgbotapi.BotAPI,
-	client *database_client.Client, translations *map[string]string) {
-
-	data := callbackQuery.Data
-	dataParts := strings.Split(data, ",")
-	videoURL := dataParts[0]
+func (yh *YoutubeHandler) HandleCallbackQueryWithFormats(callbackQuery *tgbotapi.CallbackQuery, callback *store.Callback,
+	bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) {
 
+	videoURL := callback.URL
 	formats, err := youtube_downloader.FormatWithAudioChannelsComposite(videoURL)
 	if err != nil {
 		log.Printf("FormatWithAudioChannels return %s in handleCallbackQuery", err)
 	}
 
 	// gets format by its TagNo
-	tagNo, err := strconv.Atoi(dataParts[1])
-	if err != nil {
-		errorFormat := (*translations)["errorFormat"]
-		send.SendReplyMessage(bot, callbackQuery.Message, &errorFormat)
-		return
-	}
-
+	tagNo := callback.Itag
 	var formatFile youtube.Format
 	for _, format := range formats {
 		if format.ItagNo == tagNo {
@@ -79,61 +86,147 @@ func (yh *YoutubeHandler) HandleCallbackQueryWithFormats(callbackQuery *tgbotapi
 		return
 	}
 
-	// start downloading
-	downloadingNotification := (*translations)["downloadingNotification"]
-	resp, err := send.SendReplyMessage(bot, callbackQuery.Message, &downloadingNotification)
+	dl := youtube_downloader.NewYouTubeDownloader()
+	video, err := dl.GetVideo(videoURL)
 	if err != nil {
-		log.Printf("can't send reply message: %s", err.Error())
+		log.Printf("can't get video in HandleCallbackQueryWithFormats: %s", err)
+		errorText := ErrorText(err, translations, "errorFormat")
+		send.SendReplyMessage(bot, callbackQuery.Message, &errorText)
+		return
 	}
 
-	dl := youtube_downloader.NewYouTubeDownloader()
-	video, _ := dl.GetVideo(videoURL)
-	var pathAndName string
-	if strings.HasPrefix(formatFile.MimeType, "audio") {
-		pathAndName, err = dl.DownloadWithFormat(video, formatFile)
+	fileKey := send.FileKey(video.ID, formatFile.ItagNo)
+	record := store.Job{Kind: kindFormat, URL: videoURL, Itag: formatFile.ItagNo, Title: video.Title}
+	if err := yh.enqueue(bot, callbackQuery, translations, record,
+		yh.downloadAndSend(bot, callbackQuery, client, translations, record, fileKey, downloadItag(videoURL, formatFile.ItagNo))); err != nil {
+		log.Printf("can't enqueue job: %s", err)
+	}
+}
+
+// downloadFormat downloads an audio format as is, and a video format merged with the best audio
+func downloadFormat(ctx context.Context, dl *youtube_downloader.YouTubeDownloader, video *youtube.Video, format youtube.Format) (string, error) {
+	if strings.HasPrefix(format.MimeType, "audio") {
+		return dl.DownloadWithFormat(ctx, video, format)
+	}
+	return dl.DownloadVideoWithFormatComposite(ctx, "", video, format.QualityLabel, "", "")
+}
+
+// HandleCallbackQueryWithPlaylist gets link on playlist or channel from the button's payload
+// checks the action of the button
+// if it's playlistAll with All_audio : download all videos from playlist in audio format
+// if it's playlistAll with All_video : download all videos from playlist in video format
+// if it's channelLast : download the latest videos of channel
+// if it's selected : download the selected videos of playlist in audio or video format
+// if it's page or a selection button : show another page or the changed selection of videos in place
+func (yh *YoutubeHandler) HandleCallbackQueryWithPlaylist(callbackQuery *tgbotapi.CallbackQuery, callback *store.Callback,
+	bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) {
+	playlistURL := callback.URL
+	downloader := youtube_downloader.NewYouTubeDownloader()
+
+	// buttons of a keyboard are pressed one by one, so its entries are taken from the cache of playlists
+	var playlist *youtube.Playlist
+	var err error
+	if youtube_downloader.IsChannelURL(playlistURL) {
+		playlist, err = downloader.GetChannelUploads(context.Background(), playlistURL)
 	} else {
-		pathAndName, err = dl.DownloadVideoWithFormatComposite(context.Background(), "", video, formatFile.QualityLabel, "", "")
+		playlist, err = downloader.GetPlaylist(playlistURL)
 	}
 	if err != nil {
-		log.Printf(err.Error())
-		errorFormat := (*translations)["errorFormat"]
-		send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &errorFormat)
+		log.Printf("GetPlaylist in handleCallbackQueryWithPlaylist error: %v", err)
+		errorText := ErrorText(err, translations, "somethingWentWrong")
+		send.SendReplyMessage(bot, callbackQuery.Message, &errorText)
 		return
 	}
-	// start sending
-	go sendAnswer(bot, callbackQuery, &resp, &pathAndName, client, nil, translations)
+	switch callback.Action {
+	case actionPlaylistAll:
+		if callback.Value == All_audio {
+			yh.processPlaylistAudio(bot, callbackQuery, playlist, client, translations)
+		} else {
+			yh.processPlaylistVideo(bot, callbackQuery, playlist, client, translations)
+		}
+	case actionChannelLast:
+		lastAction, count, err := parseLastAction(callback.Value)
+		if err != nil {
+			log.Printf("parseLastAction error: %v", err)
+			return
+		}
+		if lastAction == Last_audio {
+			yh.processPlaylistAudio(bot, callbackQuery, latestEntries(playlist, count), client, translations)
+		} else {
+			yh.processPlaylistVideo(bot, callbackQuery, latestEntries(playlist, count), client, translations)
+		}
+	case actionSelected:
+		selection, err := yh.selection(callbackQuery.Message.Chat.ID, callbackQuery.Message.MessageID)
+		if err != nil {
+			log.Printf("can't get selection: %v", err)
+			somethingWentWrong := (*translations)["somethingWentWrong"]
+			send.SendReplyMessage(bot, callbackQuery.Message, &somethingWentWrong)
+			return
+		}
+		selected := selectedEntries(playlist, selection)
+		if len(selected.Videos) == 0 {
+			send.SendCallbackAnswer(bot, callbackQuery.ID, (*translations)["nothingSelected"])
+			return
+		}
+		send.SendCallbackAnswer(bot, callbackQuery.ID, "")
+		if callback.Value == All_audio {
+			yh.processPlaylistAudio(bot, callbackQuery, selected, client, translations)
+		} else {
+			yh.processPlaylistVideo(bot, callbackQuery, selected, client, translations)
+		}
+	case actionPage, actionSelect, actionSelectRange, actionSelectInvert:
+		yh.editPlaylistKeyboard(bot, callbackQuery, callback, playlist, translations)
+	default:
+		log.Printf("unknown action %s of callback %s", callback.Action, callback.Token)
+	}
 }
 
-// HandleCallbackQueryWithPlaylist gets link on playlist by callbackQuery.Message.Text
-// checks callbackQuery.Data
-// if callbackQuery.Data include All_audio : download all videos from playlist in audio format
-// if callbackQuery.Data include All_video : download all videos from playlist in video format
-// else download a certain video by callbackQuery.Data
-func (yh *YoutubeHandler) HandleCallbackQueryWithPlaylist(callbackQuery *tgbotapi.CallbackQuery, bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) {
-	lines := strings.Split(callbackQuery.Message.Text, "\n") // split the string into lines
-	var playlistURL string
-	for _, line := range lines {
-		if strings.HasPrefix(line, "https://") {
-			playlistURL = line
-			break
+// editPlaylistKeyboard switches the page or changes the selection of the playlist by the pressed button
+// and edits the keyboard in place. The selection is kept by the keyboard's message, so it stays on other pages
+func (yh *YoutubeHandler) editPlaylistKeyboard(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
+	callback *store.Callback, playlist *youtube.Playlist, translations *map[string]string) {
+
+	page, err := strconv.Atoi(callback.Value)
+	if err != nil {
+		log.Printf("can't parse page: %v", err)
+		return
+	}
+
+	message := callbackQuery.Message
+	b := newButtons(callback.RequesterID, translations)
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	if youtube_downloader.IsChannelURL(callback.URL) {
+		keyboard = getKeyboardChannel(playlist, callback.URL, page, b)
+	} else {
+		selection, err := yh.selection(message.Chat.ID, message.MessageID)
+		if err != nil {
+			log.Printf("can't get selection: %v", err)
+			return
 		}
+		switch callback.Action {
+		case actionSelect:
+			selectEntry(selection, playlist.Videos, callback.VideoID)
+		case actionSelectRange:
+			toggleRangeMode(selection)
+		case actionSelectInvert:
+			invertSelection(selection, playlist.Videos)
+		}
+		if callback.Action != actionPage {
+			if err := yh.saveSelection(selection); err != nil {
+				log.Printf("can't save selection: %v", err)
+				return
+			}
+		}
+		keyboard = getKeyboardPlaylist(playlist, callback.URL, page, selection, b)
 	}
-	downloader := youtube_downloader.NewYouTubeDownloader()
 
-	playlist, err := downloader.GetPlaylist(playlistURL)
-	if err != nil {
-		log.Printf("GetPlaylist in handleCallbackQueryWithPlaylist error: %v", err)
+	if err := yh.saveButtons(b); err != nil {
+		log.Printf("can't save buttons: %v", err)
+		return
 	}
-	data := callbackQuery.Data
-	dataParts := strings.Split(data, ",")
-
-	switch {
-	case dataParts[1] == All_audio:
-		yh.processPlaylistAudio(bot, callbackQuery, playlist, client, translations)
-	case dataParts[1] == All_video:
-		yh.processPlaylistVideo(bot, callbackQuery, playlist, client, translations)
-	default:
-		yh.processSingleVideo(bot, callbackQuery, playlist, translations)
+	send.SendCallbackAnswer(bot, callbackQuery.ID, "")
+	if err := send.SendEditKeyboard(bot, message.Chat.ID, message.MessageID, &keyboard); err != nil {
+		log.Printf("can't edit keyboard: %v", err)
 	}
 }
 
@@ -141,8 +234,12 @@ func deleteFile(pathToFile string) error {
 	return os.Remove(pathToFile)
 }
 
-func sendAnswer(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, resp *tgbotapi.Message,
-	path *string, client *database_client.Client, traffic *float64, translations *map[string]string) {
+// sendAnswer sends the downloaded file in reply the way the user set and updates user's traffic.
+// The title and the link of the file are used for its caption, they may be empty.
+// fileKey is used to cache file_id of the sent file, it may be empty
+func (yh *YoutubeHandler) sendAnswer(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, resp *tgbotapi.Message,
+	path *string, fileKey string, title string, link string, client *database_client.Client, traffic *float64,
+	translations *map[string]string) error {
 
 	sendingNotification := (*translations)["sendingNotification"]
 	err := send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &sendingNotification)
@@ -151,20 +248,18 @@ func sendAnswer(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, res
 	}
 
 	defer func() {
-		err = deleteFile(*path)
+		err := deleteFile(*path)
 		if err != nil {
 			log.Printf("deleteFile return %s in handleCallbackQuery", err)
 		}
 	}()
 
-	err = send.SendFile(bot, callbackQuery.Message, *path)
-	if err != nil {
-		errorFormatSending := (*translations)["errorFormatSending"]
-		send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &errorFormatSending)
-		log.Printf("sendFile return %s in handleCallbackQuery", err)
-	} else {
-		updateUserTraffic(callbackQuery, client, traffic)
+	options := yh.fileOptions(callbackQuery.From.ID, *path, title, link)
+	if err := send.SendFile(bot, callbackQuery.Message, *path, fileKey, options); err != nil {
+		return err
 	}
+	updateUserTraffic(callbackQuery, client, traffic)
+	return nil
 }
 
 func updateUserTraffic(callbackQuery *tgbotapi.CallbackQuery, client *database_client.Client, traffic *float64) {
@@ -180,12 +275,8 @@ func updateUserTraffic(callbackQuery *tgbotapi.CallbackQuery, client *database_c
 	}
 
 	if traffic == nil {
-		parsedTraffic, err := parseTrafficFromCallbackQuery(callbackQuery)
-		if err != nil {
-			log.Printf("Can't parse traffic: %s", err.Error())
-			return
-		}
-		traffic = &parsedTraffic
+		log.Printf("No traffic to update for user: %s", callbackQuery.From.UserName)
+		return
 	}
 
 	err = client.UpdateTraffic(ctx, callbackQuery.From.UserName, user.Traffic+*traffic)
@@ -200,8 +291,8 @@ func updateUserTraffic(callbackQuery *tgbotapi.CallbackQuery, client *database_c
 func getOrCreateUser(ctx context.Context, client *database_client.Client, callbackQuery *tgbotapi.CallbackQuery) (*db.User, error) {
 	user, err := client.GetUser(ctx, callbackQuery.From.UserName)
 	if err != nil || user == nil {
-		chatID := callbackQuery.Message.Chat.ID
-		newUser := database_client.NewUser(callbackQuery.From.UserName, chatID)
+		// traffic is charged to the user who pressed the button, not to the group the keyboard is in
+		newUser := database_client.NewUser(callbackQuery.From.UserName, callbackQuery.From.ID)
 		err = client.CreateUser(ctx, newUser)
 		if err != nil {
 			return nil, err
@@ -214,44 +305,28 @@ func getOrCreateUser(ctx context.Context, client *database_client.Client, callba
 	return user, nil
 }
 
-func parseTrafficFromCallbackQuery(callbackQuery *tgbotapi.CallbackQuery) (float64, error) {
-	tokens := strings.Split(callbackQuery.Data, ",")
-	itagNo := tokens[1]
-	for _, row := range callbackQuery.Message.ReplyMarkup.InlineKeyboard {
-		for _, keyboardButton := range row {
-			tokens := strings.Split(*keyboardButton.CallbackData, ",")
-			itag := tokens[len(tokens)-1]
-			if itagNo == itag {
-				tokens = strings.Split(keyboardButton.Text, ",")
-				tokens = strings.Split(tokens[len(tokens)-1], " ")
-				traffic, err := strconv.ParseFloat(tokens[1], 64)
-				if err != nil {
-					return 0, err
-				}
-				return traffic, nil
-			}
-		}
+func checkTraffic(client *database_client.Client, callbackQuery *tgbotapi.CallbackQuery, format *youtube.Format) bool {
+	fileSize, err := getFileSize(*format) // bite
+	fileSize = fileSize / (1024 * 1024)   // Mb
+	if err != nil {
+		log.Printf("can't file size: %s", err.Error())
 	}
-	return 0, nil
+	return hasTraffic(client, callbackQuery, fileSize)
 }
 
-func checkTraffic(client *database_client.Client, callbackQuery *tgbotapi.CallbackQuery, format *youtube.Format) bool {
+// hasTraffic return true if the user can download fileSize Mb more
+func hasTraffic(client *database_client.Client, callbackQuery *tgbotapi.CallbackQuery, fileSize float64) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
 	user, err := client.GetUser(ctx, callbackQuery.From.UserName)
 	if err != nil {
-		log.Printf("can't get user by username: %s, error: %s", callbackQuery.Message.From.UserName, err.Error())
+		log.Printf("can't get user by username: %s, error: %s", callbackQuery.From.UserName, err.Error())
 		return true
 	} else if user == nil {
-		log.Printf("Get nil user: %s", callbackQuery.Message.From.UserName)
+		log.Printf("Get nil user: %s", callbackQuery.From.UserName)
 		return true
 	}
-	fileSize, err := getFileSize(*format) // bite
-	fileSize = fileSize / (1024 * 1024)   // Mb
-	if err != nil {
-		log.Printf("can't file size: %s", err.Error())
-	}
 	if user.Traffic+fileSize > TrafficLimit && user.Subscription.SubscriptionStatus != "active" {
 		return false
 	}
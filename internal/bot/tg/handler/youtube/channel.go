@@ -0,0 +1,148 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kkdai/youtube/v2"
+	"log"
+	"strconv"
+	"strings"
+	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/store"
+)
+
+const (
+	Last_audio = "lastAudio"
+	Last_video = "lastVideo"
+
+	playlistPageSize = 10
+	maxButtonTitle   = 40 // runes, longer titles of entries are cut to fit the button
+)
+
+// channelLatestCounts are the numbers of the latest uploads offered for bulk downloading
+var channelLatestCounts = []int{5, 10}
+
+// handleYoutubeChannel resolves a channel to its uploads,
+// creates and return a keyboard with the latest videos and bulk actions
+func (yh *YoutubeHandler) handleYoutubeChannel(channelURL string, b *buttons) (*tgbotapi.InlineKeyboardMarkup, error) {
+
+	uploads, err := youtube_downloader.NewYouTubeDownloader().GetChannelUploads(context.Background(), channelURL)
+	if err != nil {
+		log.Printf("GetChannelUploads in handleYoutubeChannel: %s", err)
+		return nil, err
+	}
+
+	keyboard := getKeyboardChannel(uploads, channelURL, 0, b)
+	return &keyboard, nil
+}
+
+// getKeyboardChannel return a keyboard with "download last N" buttons
+// and a page of the channel's latest uploads
+func getKeyboardChannel(uploads *youtube.Playlist, channelURL string, page int, b *buttons) tgbotapi.InlineKeyboardMarkup {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup()
+
+	for _, count := range channelLatestCounts {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+			b.button(locale.Text(b.translations, "channelLastAudio", locale.Params{"count": count}),
+				store.Callback{Action: actionChannelLast, URL: channelURL, Value: lastAction(Last_audio, count)}),
+			b.button(locale.Text(b.translations, "channelLastVideo", locale.Params{"count": count}),
+				store.Callback{Action: actionChannelLast, URL: channelURL, Value: lastAction(Last_video, count)}),
+		))
+	}
+
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, getKeyboardPlaylistPage(uploads.Videos, channelURL, page, nil, b)...)
+	return keyboard
+}
+
+// getKeyboardPlaylistPage return rows with entries of the page and a row to switch pages with the page indicator.
+// A page out of range is replaced by the nearest one.
+// With a selection entries are checkboxes, otherwise an entry sends a keyboard with its formats
+func getKeyboardPlaylistPage(entries []*youtube.PlaylistEntry, playlistURL string, page int, selection *store.Selection,
+	b *buttons) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(entries) == 0 {
+		return rows
+	}
+
+	pages := (len(entries) + playlistPageSize - 1) / playlistPageSize
+	page = max(0, min(page, pages-1))
+	start := page * playlistPageSize
+	end := min(start+playlistPageSize, len(entries))
+
+	var selected map[string]bool
+	if selection != nil {
+		selected = selectedSet(selection)
+	}
+	for _, playlistEntry := range entries[start:end] {
+		title := TruncateTitle(playlistEntry.Title, maxButtonTitle)
+		payload := store.Callback{Action: actionPlaylistVideo, URL: playlistURL, VideoID: playlistEntry.ID}
+		if selection != nil {
+			title = checkbox(selected[playlistEntry.ID], selection.RangeStart == playlistEntry.ID) + " " + title
+			payload = store.Callback{Action: actionSelect, URL: playlistURL, VideoID: playlistEntry.ID, Value: strconv.Itoa(page)}
+		}
+		rows = append(rows, []tgbotapi.InlineKeyboardButton{b.button(title, payload)})
+	}
+
+	if pages == 1 {
+		return rows
+	}
+	var navigation []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		navigation = append(navigation, b.button(b.translations["pagePrev"],
+			store.Callback{Action: actionPage, URL: playlistURL, Value: strconv.Itoa(page - 1)}))
+	}
+	navigation = append(navigation, b.button(locale.Text(b.translations, "pageIndicator", locale.Params{"page": page + 1, "pages": pages}),
+		store.Callback{Action: actionPageIndicator}))
+	if end < len(entries) {
+		navigation = append(navigation, b.button(b.translations["pageNext"],
+			store.Callback{Action: actionPage, URL: playlistURL, Value: strconv.Itoa(page + 1)}))
+	}
+	return append(rows, navigation)
+}
+
+// checkbox return a mark of the entry in the selection, the start of a range is marked until it's finished
+func checkbox(selected bool, rangeStart bool) string {
+	switch {
+	case rangeStart:
+		return "▶️"
+	case selected:
+		return "☑️"
+	default:
+		return "⬜"
+	}
+}
+
+// TruncateTitle return the title cut to size runes with an ellipsis
+func TruncateTitle(title string, size int) string {
+	runes := []rune(title)
+	if len(runes) <= size {
+		return title
+	}
+	return strings.TrimSpace(string(runes[:size-1])) + "…"
+}
+
+// lastAction return button's value for downloading the last count uploads, i.e. "lastAudio:5"
+func lastAction(action string, count int) string {
+	return action + ":" + strconv.Itoa(count)
+}
+
+// parseLastAction return the action and the count from button's value made by lastAction
+func parseLastAction(data string) (action string, count int, err error) {
+	action, countText, found := strings.Cut(data, ":")
+	if !found {
+		return "", 0, fmt.Errorf("no count in %s", data)
+	}
+	count, err = strconv.Atoi(countText)
+	return action, count, err
+}
+
+// latestEntries return a copy of the playlist with only the first count entries
+func latestEntries(playlist *youtube.Playlist, count int) *youtube.Playlist {
+	latest := *playlist
+	if count < len(latest.Videos) {
+		latest.Videos = latest.Videos[:count]
+	}
+	return &latest
+}
@@ -0,0 +1,42 @@
+package youtube
+
+import (
+	"github.com/kkdai/youtube/v2"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestGetKeyboardPlaylistPage(t *testing.T) {
+	var entries []*youtube.PlaylistEntry
+	for i := 0; i < 25; i++ {
+		entries = append(entries, &youtube.PlaylistEntry{ID: strconv.Itoa(i), Title: "video " + strconv.Itoa(i)})
+	}
+
+	translations := map[string]string{"pagePrev": "« Prev", "pageIndicator": "{page} / {pages}", "pageNext": "Next »"}
+	b := newButtons(1, &translations)
+	rows := getKeyboardPlaylistPage(entries, "https://youtube.com/playlist?list=x", 1, nil, b)
+	if assert.Len(t, rows, playlistPageSize+1) {
+		assert.Equal(t, "video 10", rows[0][0].Text)
+		navigation := rows[len(rows)-1]
+		assert.Equal(t, []string{"« Prev", "2 / 3", "Next »"},
+			[]string{navigation[0].Text, navigation[1].Text, navigation[2].Text})
+	}
+	assert.Equal(t, actionPlaylistVideo, b.callbacks[0].Action)
+	assert.Equal(t, "10", b.callbacks[0].VideoID)
+
+	// the last page has the rest of entries and no next page
+	rows = getKeyboardPlaylistPage(entries, "", 5, nil, newButtons(1, &translations))
+	if assert.Len(t, rows, 6) {
+		assert.Len(t, rows[len(rows)-1], 2)
+	}
+
+	// a single page has no navigation
+	rows = getKeyboardPlaylistPage(entries[:3], "", 0, nil, newButtons(1, &translations))
+	assert.Len(t, rows, 3)
+}
+
+func TestTruncateTitle(t *testing.T) {
+	assert.Equal(t, "short", TruncateTitle("short", 10))
+	assert.Equal(t, "Очень…", TruncateTitle("Очень длинное название", 6))
+}
@@ -0,0 +1,38 @@
+package youtube
+
+import (
+	"errors"
+	"youtube_downloader/internal/bot/tg/send"
+	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+)
+
+var errTrafficLimit = errors.New("traffic limit exceeded")
+
+// errorKeys are translation keys of messages which tell the user what to do about the error, the first match is used
+var errorKeys = []struct {
+	err error
+	key string
+}{
+	{errTrafficLimit, "trafficLimit"},
+	{youtube_downloader.ErrInvalidLink, "invalidLink"},
+	{youtube_downloader.ErrPrivate, "videoPrivate"},
+	{youtube_downloader.ErrAuthRequired, "authRequired"},
+	{youtube_downloader.ErrGeoBlocked, "geoBlocked"},
+	{youtube_downloader.ErrLiveNotSupported, "liveNotSupported"},
+	{youtube_downloader.ErrNotLive, "streamEnded"},
+	{youtube_downloader.ErrTooLarge, "fileTooLarge"},
+	{send.ErrTooLarge, "fileTooLarge"},
+	{youtube_downloader.ErrThrottled, "throttled"},
+	{youtube_downloader.ErrFFmpeg, "ffmpegFailed"},
+	{send.ErrUploadFailed, "errorFormatSending"},
+}
+
+// ErrorText return a message for the user about the error, the message by defaultKey for an unknown error
+func ErrorText(err error, translations *map[string]string, defaultKey string) string {
+	for _, errorKey := range errorKeys {
+		if errors.Is(err, errorKey.err) {
+			return (*translations)[errorKey.key]
+		}
+	}
+	return (*translations)[defaultKey]
+}
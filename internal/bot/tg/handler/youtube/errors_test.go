@@ -0,0 +1,26 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"youtube_downloader/internal/bot/tg/send"
+	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+)
+
+func TestErrorText(t *testing.T) {
+	translations := map[string]string{
+		"fileTooLarge":       "too large",
+		"geoBlocked":         "geo blocked",
+		"errorFormatSending": "sending failed",
+		"errorFormat":        "error",
+	}
+
+	tooLarge := fmt.Errorf("%w: acceptable size is 50.00 Mb", youtube_downloader.ErrTooLarge)
+	assert.Equal(t, "too large", ErrorText(tooLarge, &translations, "errorFormat"))
+	assert.Equal(t, "too large", ErrorText(send.ErrTooLarge, &translations, "errorFormat"))
+	assert.Equal(t, "geo blocked", ErrorText(fmt.Errorf("%w: reason", youtube_downloader.ErrGeoBlocked), &translations, "errorFormat"))
+	assert.Equal(t, "sending failed", ErrorText(send.ErrUploadFailed, &translations, "errorFormat"))
+	assert.Equal(t, "error", ErrorText(errors.New("unknown"), &translations, "errorFormat"))
+}
@@ -0,0 +1,137 @@
+package youtube
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kkdai/youtube/v2"
+	"log"
+	"strings"
+	"youtube_downloader/internal/bot/tg/send"
+	database_client "youtube_downloader/internal/database-client"
+	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+)
+
+const (
+	presetStartPrefix = "dl_" // deep link parameter: dl_<preset>_<video id>
+	videoURLFormat    = "https://www.youtube.com/watch?v=%s"
+	botStartURLFormat = "https://t.me/%s?start=%s"
+)
+
+// presetTranslationKeys are translation keys of the presets' titles
+var presetTranslationKeys = map[youtube_downloader.Preset]string{
+	youtube_downloader.PresetBestAudio: "presetBestAudio",
+	youtube_downloader.Preset720p:      "preset720p",
+	youtube_downloader.PresetBestFit:   "presetBestFit",
+}
+
+// HandleInlineQuery returns an inline result for every preset of the video.
+// Files sent before are returned by their cached file_id,
+// others are returned as the link with a button to download it in the bot chat
+func (yh *YoutubeHandler) HandleInlineQuery(inlineQuery *tgbotapi.InlineQuery, link string, bot *tgbotapi.BotAPI,
+	translations *map[string]string) ([]interface{}, error) {
+
+	video, err := youtube_downloader.NewYouTubeDownloader().GetVideo(link)
+	if err != nil {
+		log.Printf("GetVideo in HandleInlineQuery: %s", err)
+		return nil, err
+	}
+
+	var results []interface{}
+	for _, preset := range youtube_downloader.Presets {
+		format, err := youtube_downloader.SelectPresetFormat(video, preset)
+		if err != nil {
+			log.Printf("SelectPresetFormat in HandleInlineQuery: %s", err)
+			continue
+		}
+
+		id := video.ID + "_" + string(preset)
+		title := (*translations)[presetTranslationKeys[preset]]
+
+		fileID, cached := send.CachedFileID(send.FileKey(video.ID, format.ItagNo))
+		switch {
+		case cached && preset.IsAudio():
+			result := tgbotapi.NewInlineQueryResultCachedAudio(id, fileID)
+			result.Caption = video.Title
+			results = append(results, result)
+		case cached:
+			result := tgbotapi.NewInlineQueryResultCachedVideo(id, fileID, title)
+			result.Description = video.Title
+			result.Caption = video.Title
+			results = append(results, result)
+		default:
+			result := tgbotapi.NewInlineQueryResultArticle(id, title, fmt.Sprintf(videoURLFormat, video.ID))
+			result.Description = video.Title
+			result.ThumbURL = thumbnailURL(video)
+			startURL := fmt.Sprintf(botStartURLFormat, bot.Self.UserName, PresetStartParameter(video.ID, preset))
+			keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonURL((*translations)["inlineDownloadInBot"], startURL)))
+			result.ReplyMarkup = &keyboard
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// PresetStartParameter return a deep link parameter to download the video with the preset
+func PresetStartParameter(videoID string, preset youtube_downloader.Preset) string {
+	return presetStartPrefix + string(preset) + "_" + videoID
+}
+
+// IsPresetStartParameter return true if the /start parameter was made by PresetStartParameter
+func IsPresetStartParameter(parameter string) bool {
+	return strings.HasPrefix(parameter, presetStartPrefix)
+}
+
+// parsePresetStartParameter return the video id and the preset from the deep link parameter
+func parsePresetStartParameter(parameter string) (string, youtube_downloader.Preset, error) {
+	presetName, videoID, found := strings.Cut(strings.TrimPrefix(parameter, presetStartPrefix), "_")
+	if !found || videoID == "" {
+		return "", "", fmt.Errorf("invalid preset parameter: %s", parameter)
+	}
+	preset, err := youtube_downloader.ParsePreset(presetName)
+	return videoID, preset, err
+}
+
+// HandlePreset downloads a video with the preset from the /start deep link and sends it to the bot chat.
+// The download goes the same way as if the user pressed a format button on the message
+func (yh *YoutubeHandler) HandlePreset(message *tgbotapi.Message, parameter string, bot *tgbotapi.BotAPI,
+	client *database_client.Client, translations *map[string]string) {
+
+	videoID, preset, err := parsePresetStartParameter(parameter)
+	if err != nil {
+		log.Println(err)
+		invalidLink := (*translations)["invalidLink"]
+		send.SendReplyMessage(bot, message, &invalidLink)
+		return
+	}
+
+	dl := youtube_downloader.NewYouTubeDownloader()
+	video, err := dl.GetVideo(fmt.Sprintf(videoURLFormat, videoID))
+	if err != nil {
+		log.Printf("can't get video in HandlePreset: %s", err)
+		errorText := ErrorText(err, translations, "somethingWentWrong")
+		send.SendReplyMessage(bot, message, &errorText)
+		return
+	}
+
+	format, err := youtube_downloader.SelectPresetFormat(video, preset)
+	if err != nil {
+		log.Printf("SelectPresetFormat in HandlePreset: %s", err)
+		errorText := ErrorText(err, translations, "errorFormat")
+		send.SendReplyMessage(bot, message, &errorText)
+		return
+	}
+
+	yh.enqueueFormat(message, video, format, bot, client, translations)
+}
+
+// thumbnailURL return the largest thumbnail of the video
+func thumbnailURL(video *youtube.Video) string {
+	var thumbnail youtube.Thumbnail
+	for _, t := range video.Thumbnails {
+		if t.Width*t.Height > thumbnail.Width*thumbnail.Height {
+			thumbnail = t
+		}
+	}
+	return thumbnail.URL
+}
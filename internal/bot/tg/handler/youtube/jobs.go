@@ -0,0 +1,269 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kkdai/youtube/v2"
+	"log"
+	"time"
+	"youtube_downloader/internal/bot/tg/send"
+	database_client "youtube_downloader/internal/database-client"
+	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/queue"
+	"youtube_downloader/internal/store"
+)
+
+// kinds of saved jobs, a job is resumed after a restart by its kind
+const (
+	kindFormat        = "format"        // a format of a video by its itag
+	kindPlaylistAudio = "playlistAudio" // the best audio of a playlist's video
+	kindPlaylistVideo = "playlistVideo" // the smallest video of a playlist's video
+	kindMusicAlbum    = "musicAlbum"
+	kindRecording     = "recording"
+)
+
+// RecordingClass is the class of jobs recording live streams, they run on their own workers of the queue
+const RecordingClass = kindRecording
+
+// Resumable return true if a saved job of the kind starts over after a restart, other kinds are failed by ResumeJob
+func Resumable(kind string) bool {
+	switch kind {
+	case kindFormat, kindPlaylistAudio, kindPlaylistVideo:
+		return true
+	}
+	return false
+}
+
+// jobFunc does the work of a queued job, resp is the message to show its state in
+type jobFunc func(ctx context.Context, job *queue.Job, resp *tgbotapi.Message) error
+
+// downloadFunc downloads the file of a job and returns its path
+type downloadFunc func(ctx context.Context, job *queue.Job) (string, error)
+
+// enqueue replies that the job is queued with its position and adds the job to the queue.
+// The job isn't added if the reply can't be sent, since the job shows its state in it
+func (yh *YoutubeHandler) enqueue(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
+	translations *map[string]string, record store.Job, run jobFunc) error {
+
+	position := yh.Queue.NextPosition(callbackQuery.From.ID)
+	queuedNotification := locale.Text(*translations, "queuedNotification", locale.Params{"position": position})
+	resp, err := send.SendReplyMessage(bot, callbackQuery.Message, &queuedNotification)
+	if err != nil {
+		return fmt.Errorf("can't send reply message: %w", err)
+	}
+
+	record.StatusMessageID = resp.MessageID
+	yh.addJob(bot, callbackQuery, translations, record, &resp, run)
+	return nil
+}
+
+// enqueueBatch adds a job for every record with a single reply about the queued batch.
+// Every job replies on its own when it starts
+func (yh *YoutubeHandler) enqueueBatch(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
+	translations *map[string]string, records []store.Job, runs []jobFunc) {
+
+	if len(runs) == 0 {
+		return
+	}
+
+	position := yh.Queue.NextPosition(callbackQuery.From.ID)
+	queuedNotification := locale.Plural(*translations, "queuedBatchNotification", len(runs), locale.Params{"position": position})
+	if _, err := send.SendReplyMessage(bot, callbackQuery.Message, &queuedNotification); err != nil {
+		log.Printf("can't send reply message: %s", err.Error())
+	}
+
+	for i, run := range runs {
+		yh.addJob(bot, callbackQuery, translations, records[i], nil, run)
+	}
+}
+
+// addJob saves the record of the job of the user who pressed the button and adds the job to the queue
+func (yh *YoutubeHandler) addJob(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
+	translations *map[string]string, record store.Job, resp *tgbotapi.Message, run jobFunc) {
+
+	if record.ID == "" {
+		record.ID = yh.Queue.NewID()
+	}
+	record.UserID = callbackQuery.From.ID
+	record.Username = callbackQuery.From.UserName
+	record.LanguageCode = callbackQuery.From.LanguageCode
+	record.ChatID = callbackQuery.Message.Chat.ID
+	record.MessageID = callbackQuery.Message.MessageID
+	record.State = string(queue.StateQueued)
+
+	if yh.Store != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := yh.Store.SaveJob(ctx, &record); err != nil {
+			log.Printf("can't save job %s: %s", record.ID, err)
+		}
+	}
+
+	yh.Queue.Enqueue(yh.newJob(bot, callbackQuery, translations, record, resp, run))
+}
+
+// newJob return a job of the record.
+// The job shows the downloading notification in resp, or in a new reply if resp is nil,
+// and replaces it with an error message if run fails. The job fails if the new reply can't be sent
+func (yh *YoutubeHandler) newJob(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
+	translations *map[string]string, record store.Job, resp *tgbotapi.Message, run jobFunc) *queue.Job {
+
+	return &queue.Job{
+		ID:     record.ID,
+		UserID: record.UserID,
+		ChatID: record.ChatID,
+		Title:  record.Title,
+		Class:  jobClass(record.Kind),
+		Run: func(ctx context.Context, job *queue.Job) error {
+			downloadingNotification := (*translations)["downloadingNotification"]
+			if resp == nil {
+				reply, err := send.SendReplyMessage(bot, callbackQuery.Message, &downloadingNotification)
+				if err != nil {
+					// i.e. the user blocked the bot or deleted the message with the link
+					return fmt.Errorf("can't send reply message: %w", err)
+				}
+				resp = &reply
+				yh.saveStatusMessage(job.ID, resp.MessageID)
+			} else if err := send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &downloadingNotification); err != nil {
+				log.Printf("can't send edit message: %s", err.Error())
+			}
+
+			err := run(ctx, job, resp)
+			if err != nil {
+				errorText := ErrorText(err, translations, "errorFormat")
+				if job.Interrupted() {
+					errorText = (*translations)["jobPaused"]
+				} else if ctx.Err() != nil {
+					errorText = (*translations)["jobCanceled"]
+				}
+				send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &errorText)
+			}
+			return err
+		},
+		OnCanceled: func(job *queue.Job) {
+			if resp == nil {
+				return
+			}
+			jobCanceled := (*translations)["jobCanceled"]
+			send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &jobCanceled)
+		},
+	}
+}
+
+// jobClass return the class of workers running jobs of the kind
+func jobClass(kind string) string {
+	if kind == kindRecording {
+		return RecordingClass
+	}
+	return ""
+}
+
+// saveStatusMessage saves the message showing the state of the job, so it's reused after a restart
+func (yh *YoutubeHandler) saveStatusMessage(id string, messageID int) {
+	if yh.Store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := yh.Store.SetJobStatusMessage(ctx, id, messageID); err != nil {
+		log.Printf("can't save status message of job %s: %s", id, err)
+	}
+}
+
+// downloadAndSend return a jobFunc which downloads a file by download and sends it in reply.
+// The record gives the title and the link of the file for its caption.
+// fileKey is used to cache file_id of the sent file, it may be empty
+func (yh *YoutubeHandler) downloadAndSend(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, client *database_client.Client,
+	translations *map[string]string, record store.Job, fileKey string, download downloadFunc) jobFunc {
+
+	return func(ctx context.Context, job *queue.Job, resp *tgbotapi.Message) error {
+		job.SetState(queue.StateDownloading)
+		ctx = youtube_downloader.WithProgress(ctx, func(stage youtube_downloader.Stage, percent float64) {
+			if stage == youtube_downloader.StageMerging {
+				job.SetState(queue.StateMerging)
+				return
+			}
+			job.SetProgress(percent)
+		})
+		path, err := download(ctx, job)
+		if err != nil {
+			return err
+		}
+
+		job.SetState(queue.StateUploading)
+		fileSize := fileSizeMb(path)
+		return yh.sendAnswer(bot, callbackQuery, resp, &path, fileKey, record.Title, record.URL, client, &fileSize, translations)
+	}
+}
+
+// downloadItag return a downloadFunc downloading the format of the video by its itag
+func downloadItag(videoURL string, itag int) downloadFunc {
+	return func(ctx context.Context, job *queue.Job) (string, error) {
+		dl := youtube_downloader.NewYouTubeDownloader()
+		video, err := dl.GetVideo(videoURL)
+		if err != nil {
+			return "", err
+		}
+
+		formats := video.Formats.Itag(itag)
+		if len(formats) == 0 {
+			return "", fmt.Errorf("no format with itag %d for %s", itag, video.ID)
+		}
+		return downloadFormat(ctx, dl, video, formats[0])
+	}
+}
+
+// ResumeJob adds the job saved before a restart back to the queue.
+// Jobs which can't be resumed, i.e. recordings of live streams, are failed with a message to the user
+func (yh *YoutubeHandler) ResumeJob(record store.Job, bot *tgbotapi.BotAPI, client *database_client.Client,
+	translations *map[string]string) error {
+
+	callbackQuery := &tgbotapi.CallbackQuery{
+		From: &tgbotapi.User{ID: record.UserID, UserName: record.Username, LanguageCode: record.LanguageCode},
+		Message: &tgbotapi.Message{
+			MessageID: record.MessageID,
+			Chat:      &tgbotapi.Chat{ID: record.ChatID},
+		},
+	}
+
+	var resp *tgbotapi.Message
+	if record.StatusMessageID != 0 {
+		resp = &tgbotapi.Message{MessageID: record.StatusMessageID, Chat: &tgbotapi.Chat{ID: record.ChatID}}
+	}
+
+	var run jobFunc
+	switch record.Kind {
+	case kindFormat:
+		fileKey := ""
+		if videoID, err := youtube.ExtractVideoID(record.URL); err == nil {
+			fileKey = send.FileKey(videoID, record.Itag)
+		}
+		run = yh.downloadAndSend(bot, callbackQuery, client, translations, record, fileKey, downloadItag(record.URL, record.Itag))
+	case kindPlaylistAudio, kindPlaylistVideo:
+		prefix := youtube_downloader.AUDIO_PREFIX
+		if record.Kind == kindPlaylistVideo {
+			prefix = youtube_downloader.VIDEO_PREFIX
+		}
+		run = yh.downloadAndSend(bot, callbackQuery, client, translations, record, "",
+			func(ctx context.Context, job *queue.Job) (string, error) {
+				return downloadBest(ctx, callbackQuery, client, record.URL, prefix)
+			})
+	default:
+		jobInterrupted := locale.Text(*translations, "jobInterrupted", locale.Params{"title": record.Title})
+		if resp != nil {
+			send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &jobInterrupted)
+		} else {
+			send.SendReplyMessage(bot, callbackQuery.Message, &jobInterrupted)
+		}
+		return fmt.Errorf("job %s of kind %s can't be resumed", record.ID, record.Kind)
+	}
+
+	jobResumed := locale.Text(*translations, "jobResumed", locale.Params{"title": record.Title})
+	if resp != nil {
+		send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &jobResumed)
+	}
+	yh.addJob(bot, callbackQuery, translations, record, resp, run)
+	return nil
+}
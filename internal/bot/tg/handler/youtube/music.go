@@ -0,0 +1,162 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kkdai/youtube/v2"
+	"log"
+	"net/url"
+	"os"
+	"youtube_downloader/internal/bot/tg/send"
+	database_client "youtube_downloader/internal/database-client"
+	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/queue"
+	"youtube_downloader/internal/store"
+)
+
+const (
+	youtubeMusicHost = "music.youtube.com"
+)
+
+// isYoutubeMusicPlaylist return true if the link is a YouTube Music album or playlist
+func isYoutubeMusicPlaylist(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return u.Host == youtubeMusicHost && u.Query().Get("list") != ""
+}
+
+// handleYoutubeMusic gets an album or a playlist from YouTube Music
+// and returns a keyboard to download it as tagged audio tracks
+func (yh *YoutubeHandler) handleYoutubeMusic(playlistURL string, b *buttons) (*tgbotapi.InlineKeyboardMarkup, error) {
+
+	playlist, err := youtube_downloader.NewYouTubeDownloader().GetPlaylist(playlistURL)
+	if err != nil {
+		log.Printf("GetPlaylist in handleYoutubeMusic: %s", err)
+		return nil, err
+	}
+
+	keyboard := getKeyboardMusic(playlist, playlistURL, b)
+	return &keyboard, nil
+}
+
+// getKeyboardMusic return a keyboard with a button to download the whole album as audio
+func getKeyboardMusic(playlist *youtube.Playlist, playlistURL string, b *buttons) tgbotapi.InlineKeyboardMarkup {
+	button := b.button(locale.Text(b.translations, "musicAlbumButton", locale.Params{"album": youtube_downloader.AlbumTitle(playlist)}),
+		store.Callback{Action: actionMusicAlbum, URL: playlistURL})
+	return tgbotapi.NewInlineKeyboardMarkup([]tgbotapi.InlineKeyboardButton{button})
+}
+
+// HandleCallbackQueryWithMusic gets link on album from the button's payload
+// and downloads all its tracks as tagged audio in playlist order
+func (yh *YoutubeHandler) HandleCallbackQueryWithMusic(callbackQuery *tgbotapi.CallbackQuery, callback *store.Callback,
+	bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) {
+
+	playlistURL := callback.URL
+	downloader := youtube_downloader.NewYouTubeDownloader()
+
+	playlist, err := downloader.GetPlaylist(playlistURL)
+	if err != nil {
+		log.Printf("GetPlaylist in HandleCallbackQueryWithMusic error: %v", err)
+		somethingWentWrong := (*translations)["somethingWentWrong"]
+		send.SendReplyMessage(bot, callbackQuery.Message, &somethingWentWrong)
+		return
+	}
+
+	yh.processMusicAlbum(bot, callbackQuery, playlist, playlistURL, client, translations)
+}
+
+// processMusicAlbum queues a job downloading every track of the album with album, artist,
+// track number tags and the album cover. The album is a single job to keep the tracks' order in the chat
+func (yh *YoutubeHandler) processMusicAlbum(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
+	playlist *youtube.Playlist, playlistURL string, client *database_client.Client, translations *map[string]string) {
+
+	album := youtube_downloader.AlbumTitle(playlist)
+	record := store.Job{Kind: kindMusicAlbum, URL: playlistURL, Title: album}
+	err := yh.enqueue(bot, callbackQuery, translations, record, func(ctx context.Context, job *queue.Job, resp *tgbotapi.Message) error {
+		downloader := youtube_downloader.NewYouTubeDownloader()
+		job.SetState(queue.StateDownloading)
+
+		coverPath, err := downloader.DownloadCover(ctx, youtube_downloader.AlbumCoverURL(playlist))
+		if err != nil {
+			log.Printf("can't download album cover: %v", err)
+		} else {
+			defer deleteFile(coverPath)
+		}
+
+		for i, playlistEntry := range playlist.Videos {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			job.SetProgress(float64(i) * 100 / float64(len(playlist.Videos)))
+
+			// the first track uses the queued message, others reply on their own
+			if i > 0 {
+				downloadingNotification := (*translations)["downloadingNotification"]
+				reply, err := send.SendReplyMessage(bot, callbackQuery.Message, &downloadingNotification)
+				if err != nil {
+					log.Printf("can't send reply message: %s", err.Error())
+				}
+				resp = &reply
+			}
+
+			video, err := downloader.GetVideoFromPlaylistEntry(playlistEntry)
+			if err != nil {
+				log.Printf("VideoFromPlaylistEntry error: %v", err)
+				continue
+			}
+
+			formats, err := youtube_downloader.WithFormats(&video.Formats, youtube_downloader.AUDIO_PREFIX)
+			if err != nil || len(formats) == 0 {
+				log.Printf("no audio formats for %s: %v", video.ID, err)
+				continue
+			}
+			formats.Sort()
+
+			if !checkTraffic(client, callbackQuery, &formats[0]) {
+				return errTrafficLimit
+			}
+
+			tags := youtube_downloader.AudioTags{
+				Title:      playlistEntry.Title,
+				Artist:     youtube_downloader.ArtistName(playlistEntry.Author),
+				Album:      album,
+				Track:      i + 1,
+				TrackTotal: len(playlist.Videos),
+				CoverPath:  coverPath,
+			}
+			path, err := downloader.DownloadTaggedAudio(ctx, video, tags)
+			if err != nil {
+				log.Printf("DownloadTaggedAudio error: %v", err)
+				errorText := ErrorText(err, translations, "errorFormat")
+				send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &errorText)
+				continue
+			}
+
+			fileSize := fileSizeMb(path)
+			link := fmt.Sprintf(videoURLFormat, playlistEntry.ID)
+			if err := yh.sendAnswer(bot, callbackQuery, resp, &path, "", playlistEntry.Title, link, client, &fileSize, translations); err != nil {
+				log.Printf("sendAnswer error: %v", err)
+				errorText := ErrorText(err, translations, "errorFormatSending")
+				send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &errorText)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("can't enqueue job: %s", err)
+	}
+}
+
+// fileSizeMb return a size of the downloaded file in Mb
+func fileSizeMb(path string) float64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Printf("can't get file size: %s", err.Error())
+		return 0
+	}
+	return float64(info.Size()) / (1024 * 1024)
+}
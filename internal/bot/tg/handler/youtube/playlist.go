@@ -1,22 +1,18 @@
 package youtube
 
 import (
-	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/kkdai/youtube/v2"
 	"log"
+	"strconv"
 	youtube_downloader "youtube_downloader/internal/downloader/youtube"
-)
-
-const (
-	youtubeCheckPlaylist = "https://youtu.be/check" // for checking youtube link format
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/store"
 )
 
 // handleYoutubePlaylist gets playlist,
 // creates and return keyboard with all videos from it
-func (yh *YoutubeHandler) handleYoutubePlaylist(message *tgbotapi.Message) (*tgbotapi.InlineKeyboardMarkup, error) {
-
-	playlistURL := message.Text
+func (yh *YoutubeHandler) handleYoutubePlaylist(playlistURL string, b *buttons) (*tgbotapi.InlineKeyboardMarkup, error) {
 
 	downloader := youtube_downloader.NewYouTubeDownloader()
 	playlist, err := downloader.GetPlaylist(playlistURL)
@@ -25,29 +21,43 @@ func (yh *YoutubeHandler) handleYoutubePlaylist(message *tgbotapi.Message) (*tgb
 		return nil, err
 	}
 
-	keyboard := getKeyboardPlaylist(playlist)
+	keyboard := getKeyboardPlaylist(playlist, playlistURL, 0, &store.Selection{}, b)
 	return &keyboard, nil
 }
 
-// getKeyboardPlaylist return a keyboard with all videos from playlist. Button's data include youtube url (for checking link while handling)
-// and playlistEntry.ID for certain videos, and All_video and All_audio for downloading all playlist
-func getKeyboardPlaylist(playlist *youtube.Playlist) tgbotapi.InlineKeyboardMarkup {
+// getKeyboardPlaylist return a keyboard with a page of videos from playlist to select. Button's payload include the playlist url
+// and playlistEntry.ID for certain videos, and All_video and All_audio for downloading all or selected videos of playlist
+func getKeyboardPlaylist(playlist *youtube.Playlist, playlistURL string, page int, selection *store.Selection,
+	b *buttons) tgbotapi.InlineKeyboardMarkup {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup()
 
-	button := tgbotapi.NewInlineKeyboardButtonData(
-		fmt.Sprintf("%s", "Download all: video"), youtubeCheckPlaylist+","+All_video)
+	button := b.button(b.translations["playlistAllVideo"], store.Callback{Action: actionPlaylistAll, URL: playlistURL, Value: All_video})
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{button})
 
-	button = tgbotapi.NewInlineKeyboardButtonData(
-		fmt.Sprintf("%s", "Download all: audio"), youtubeCheckPlaylist+","+All_audio)
+	button = b.button(b.translations["playlistAllAudio"], store.Callback{Action: actionPlaylistAll, URL: playlistURL, Value: All_audio})
 	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{button})
 
-	for _, playlistEntry := range playlist.Videos {
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard,
+		getKeyboardPlaylistPage(playlist.Videos, playlistURL, page, selection, b)...)
 
-		button := tgbotapi.NewInlineKeyboardButtonData(
-			fmt.Sprintf("%s", playlistEntry.Title), youtubeCheckPlaylist+","+playlistEntry.ID)
-		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{button})
+	rangeText := b.translations["playlistSelectRange"]
+	if selection.RangeMode && selection.RangeStart == "" {
+		rangeText = b.translations["playlistRangeFirst"]
+	} else if selection.RangeMode {
+		rangeText = b.translations["playlistRangeLast"]
 	}
-
+	pageValue := strconv.Itoa(page)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+		b.button(rangeText, store.Callback{Action: actionSelectRange, URL: playlistURL, Value: pageValue}),
+		b.button(b.translations["playlistInvert"], store.Callback{Action: actionSelectInvert, URL: playlistURL, Value: pageValue}),
+	))
+
+	count := len(selection.VideoIDs)
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, tgbotapi.NewInlineKeyboardRow(
+		b.button(locale.Text(b.translations, "playlistSelectedAudio", locale.Params{"count": count}),
+			store.Callback{Action: actionSelected, URL: playlistURL, Value: All_audio}),
+		b.button(locale.Text(b.translations, "playlistSelectedVideo", locale.Params{"count": count}),
+			store.Callback{Action: actionSelected, URL: playlistURL, Value: All_video}),
+	))
 	return keyboard
 }
@@ -1,132 +1,102 @@
 package youtube
 
 import (
+	"context"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/kkdai/youtube/v2"
 	"log"
-	"strings"
 	"youtube_downloader/internal/bot/tg/send"
 	database_client "youtube_downloader/internal/database-client"
 	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+	"youtube_downloader/internal/queue"
+	"youtube_downloader/internal/store"
 )
 
+// processPlaylistAudio queues a job downloading the best audio for every video of the playlist
 func (yh *YoutubeHandler) processPlaylistAudio(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
 	playlist *youtube.Playlist, client *database_client.Client, translations *map[string]string) {
-	downloader := youtube_downloader.NewYouTubeDownloader()
-	for _, playlistEntry := range playlist.Videos {
-		video, err := downloader.GetVideoFromPlaylistEntry(playlistEntry)
-		if err != nil {
-			log.Printf("VideoFromPlaylistEntry error: %v", err)
-			continue
-		}
-
-		formats := video.Formats.WithAudioChannels()
-		formats.Sort()
-		formats, err = youtube_downloader.WithFormats(&video.Formats, youtube_downloader.AUDIO_PREFIX)
-
-		if !checkTraffic(client, callbackQuery, &formats[0]) {
-			trafficLimit := (*translations)["trafficLimit"]
-			_, err := send.SendReplyMessage(bot, callbackQuery.Message, &trafficLimit)
-			if err != nil {
-				log.Printf("can't send reply message: %s", err.Error())
-			}
-			return
-		}
+	yh.processPlaylist(bot, callbackQuery, playlist, client, translations, youtube_downloader.AUDIO_PREFIX)
+}
 
-		// start downloading
-		downloadingNotification := (*translations)["downloadingNotification"]
-		resp, err := send.SendReplyMessage(bot, callbackQuery.Message, &downloadingNotification)
-		if err != nil {
-			log.Printf("can't send reply message: %s", err.Error())
-		}
+// processPlaylistVideo queues a job downloading the best video for every video of the playlist
+func (yh *YoutubeHandler) processPlaylistVideo(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
+	playlist *youtube.Playlist, client *database_client.Client, translations *map[string]string) {
+	yh.processPlaylist(bot, callbackQuery, playlist, client, translations, youtube_downloader.VIDEO_PREFIX)
+}
 
-		path, err := downloader.DownloadAudio(video)
-		if err != nil {
-			log.Printf("downloadAudio error: %v", err)
-			continue
-		}
-		fileSize, err := getFileSize(formats[0]) // bite
-		fileSize = fileSize / (1024 * 1024)      // Mb
-		if err != nil {
-			log.Printf("can't file size: %s", err.Error())
-		}
+// processPlaylist queues a job for every video of the playlist with formats of the mime type prefix.
+// Videos' metadata is fetched by the jobs, so a long playlist doesn't hold up the bot
+func (yh *YoutubeHandler) processPlaylist(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
+	playlist *youtube.Playlist, client *database_client.Client, translations *map[string]string, prefix string) {
 
-		// start sending
-		go sendAnswer(bot, callbackQuery, &resp, &path, client, &fileSize, translations)
+	kind := kindPlaylistAudio
+	if prefix == youtube_downloader.VIDEO_PREFIX {
+		kind = kindPlaylistVideo
 	}
-}
 
-func (yh *YoutubeHandler) processPlaylistVideo(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
-	playlist *youtube.Playlist, client *database_client.Client, translations *map[string]string) {
-	downloader := youtube_downloader.NewYouTubeDownloader()
+	var records []store.Job
+	var runs []jobFunc
 	for _, playlistEntry := range playlist.Videos {
-		video, err := downloader.GetVideoFromPlaylistEntry(playlistEntry)
-		if err != nil {
-			log.Printf("VideoFromPlaylistEntry error: %v", err)
-			continue
-		}
-
-		formats := video.Formats.WithAudioChannels()
-		formats.Sort()
-		formats, err = youtube_downloader.WithFormats(&video.Formats, youtube_downloader.VIDEO_PREFIX)
+		videoURL := fmt.Sprintf(videoURLFormat, playlistEntry.ID)
+		record := store.Job{Kind: kind, URL: videoURL, Title: playlistEntry.Title}
+		records = append(records, record)
+		runs = append(runs, yh.downloadAndSend(bot, callbackQuery, client, translations, record, "",
+			func(ctx context.Context, job *queue.Job) (string, error) {
+				return downloadBest(ctx, callbackQuery, client, videoURL, prefix)
+			}))
+	}
+	yh.enqueueBatch(bot, callbackQuery, translations, records, runs)
+}
 
-		if !checkTraffic(client, callbackQuery, &video.Formats[len(formats)-1]) {
-			trafficLimit := (*translations)["trafficLimit"]
-			_, err := send.SendReplyMessage(bot, callbackQuery.Message, &trafficLimit)
-			if err != nil {
-				log.Printf("can't send reply message: %s", err.Error())
-			}
-			return
-		}
+// downloadBest downloads the video as audio or video by the mime type prefix
+func downloadBest(ctx context.Context, callbackQuery *tgbotapi.CallbackQuery, client *database_client.Client,
+	videoURL string, prefix string) (string, error) {
 
-		// start downloading
-		downloadingNotification := (*translations)["downloadingNotification"]
-		resp, err := send.SendReplyMessage(bot, callbackQuery.Message, &downloadingNotification)
-		if err != nil {
-			log.Printf("can't send reply message: %s", err.Error())
-		}
+	downloader := youtube_downloader.NewYouTubeDownloader()
+	video, err := downloader.GetVideo(videoURL)
+	if err != nil {
+		return "", fmt.Errorf("GetVideo error: %w", err)
+	}
 
-		path, err := downloader.DownloadVideo(video)
-		if err != nil {
-			log.Printf("downloadVideo error: %v", err)
-			continue
-		}
+	formats, err := youtube_downloader.WithFormats(&video.Formats, prefix)
+	if err != nil || len(formats) == 0 {
+		return "", fmt.Errorf("no %s formats for %s: %v", prefix, video.ID, err)
+	}
+	formats.Sort()
 
-		fileSize, err := getFileSize(formats[len(formats)-1]) // bite
-		fileSize = fileSize / (1024 * 1024)                   // Mb
-		if err != nil {
-			log.Printf("can't file size: %s", err.Error())
-		}
+	// the audio is the best one, the video is the smallest one
+	format := formats[0]
+	if prefix == youtube_downloader.VIDEO_PREFIX {
+		format = formats[len(formats)-1]
+	}
+	if !checkTraffic(client, callbackQuery, &format) {
+		return "", errTrafficLimit
+	}
 
-		// start sending
-		go sendAnswer(bot, callbackQuery, &resp, &path, client, &fileSize, translations)
+	if prefix == youtube_downloader.AUDIO_PREFIX {
+		return downloader.DownloadAudio(ctx, video)
 	}
+	return downloader.DownloadVideo(ctx, video)
 }
 
+// processSingleVideo sends a keyboard with formats of the playlist's video from the button's payload
 func (yh *YoutubeHandler) processSingleVideo(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery,
-	playlist *youtube.Playlist, translations *map[string]string) {
+	callback *store.Callback, translations *map[string]string) {
 	downloader := youtube_downloader.NewYouTubeDownloader()
 
-	data := callbackQuery.Data
-	dataParts := strings.Split(data, ",")
-	videoID := dataParts[1] // video id
-	var video *youtube.Video
-	for _, playlistEntry := range playlist.Videos {
-		if playlistEntry.ID == videoID {
-			video, _ = downloader.GetVideoFromPlaylistEntry(playlistEntry)
-			break
-		}
-	}
-
-	videoURL := fmt.Sprintf("https://www.youtube.com/watch?v=%s", video.ID)
+	videoURL := fmt.Sprintf(videoURLFormat, callback.VideoID)
 	video, err := downloader.GetVideo(videoURL)
 	if err != nil {
 		log.Println("can't get video in processSingleVideo: " + err.Error())
 		return
 	}
 	formats := video.Formats
-	keyboard, err := getKeyboardVideoFormats(&formats, &videoURL)
+	b := newButtons(callback.RequesterID, translations)
+	keyboard, err := getKeyboardVideoFormats(&formats, videoURL, video.ID, b)
+	if err == nil {
+		err = yh.saveButtons(b)
+	}
 	if err != nil {
 		log.Println("Error after getKeyboardVideoFormats in processSingleVideo: " + err.Error())
 		somethingWentWrong := (*translations)["somethingWentWrong"]
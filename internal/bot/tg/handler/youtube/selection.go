@@ -0,0 +1,102 @@
+package youtube
+
+import (
+	"context"
+	"github.com/kkdai/youtube/v2"
+	"slices"
+	"time"
+	"youtube_downloader/internal/store"
+)
+
+// selectedSet return ids of the selected entries as a set
+func selectedSet(selection *store.Selection) map[string]bool {
+	selected := make(map[string]bool, len(selection.VideoIDs))
+	for _, videoID := range selection.VideoIDs {
+		selected[videoID] = true
+	}
+	return selected
+}
+
+// setSelected replaces the selected entries by ones from the set in the playlist order
+func setSelected(selection *store.Selection, entries []*youtube.PlaylistEntry, selected map[string]bool) {
+	selection.VideoIDs = nil
+	for _, entry := range entries {
+		if selected[entry.ID] {
+			selection.VideoIDs = append(selection.VideoIDs, entry.ID)
+		}
+	}
+}
+
+// selectEntry toggles the entry.
+// In the range mode the first pressed entry starts the range and the second one selects all entries between them
+func selectEntry(selection *store.Selection, entries []*youtube.PlaylistEntry, videoID string) {
+	selected := selectedSet(selection)
+	switch {
+	case !selection.RangeMode:
+		selected[videoID] = !selected[videoID]
+	case selection.RangeStart == "":
+		selection.RangeStart = videoID
+		return
+	default:
+		start := slices.IndexFunc(entries, func(entry *youtube.PlaylistEntry) bool { return entry.ID == selection.RangeStart })
+		end := slices.IndexFunc(entries, func(entry *youtube.PlaylistEntry) bool { return entry.ID == videoID })
+		if start > end {
+			start, end = end, start
+		}
+		if start >= 0 {
+			for _, entry := range entries[start : end+1] {
+				selected[entry.ID] = true
+			}
+		}
+		selection.RangeMode, selection.RangeStart = false, ""
+	}
+	setSelected(selection, entries, selected)
+}
+
+// toggleRangeMode turns on the range mode or cancels it
+func toggleRangeMode(selection *store.Selection) {
+	selection.RangeMode = !selection.RangeMode
+	selection.RangeStart = ""
+}
+
+// invertSelection selects entries which aren't selected and deselects the rest
+func invertSelection(selection *store.Selection, entries []*youtube.PlaylistEntry) {
+	selected := selectedSet(selection)
+	for _, entry := range entries {
+		selected[entry.ID] = !selected[entry.ID]
+	}
+	setSelected(selection, entries, selected)
+}
+
+// selectedEntries return a copy of the playlist with only the selected entries
+func selectedEntries(playlist *youtube.Playlist, selection *store.Selection) *youtube.Playlist {
+	selected := selectedSet(selection)
+	result := *playlist
+	result.Videos = nil
+	for _, entry := range playlist.Videos {
+		if selected[entry.ID] {
+			result.Videos = append(result.Videos, entry)
+		}
+	}
+	return &result
+}
+
+// selection return the selection of the keyboard's message
+func (yh *YoutubeHandler) selection(chatID int64, messageID int) (*store.Selection, error) {
+	if yh.Store == nil {
+		return nil, errNoStore
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return yh.Store.Selection(ctx, chatID, messageID)
+}
+
+// saveSelection saves the selection of the keyboard's message
+func (yh *YoutubeHandler) saveSelection(selection *store.Selection) error {
+	if yh.Store == nil {
+		return errNoStore
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return yh.Store.SaveSelection(ctx, selection)
+}
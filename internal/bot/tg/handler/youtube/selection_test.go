@@ -0,0 +1,40 @@
+package youtube
+
+import (
+	"github.com/kkdai/youtube/v2"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"youtube_downloader/internal/store"
+)
+
+func TestSelectEntry(t *testing.T) {
+	var entries []*youtube.PlaylistEntry
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		entries = append(entries, &youtube.PlaylistEntry{ID: id})
+	}
+	selection := &store.Selection{}
+
+	selectEntry(selection, entries, "c")
+	selectEntry(selection, entries, "a")
+	assert.Equal(t, []string{"a", "c"}, selection.VideoIDs)
+	selectEntry(selection, entries, "c")
+	assert.Equal(t, []string{"a"}, selection.VideoIDs)
+
+	// a range is selected in any direction
+	toggleRangeMode(selection)
+	selectEntry(selection, entries, "e")
+	assert.Equal(t, "e", selection.RangeStart)
+	assert.Equal(t, []string{"a"}, selection.VideoIDs)
+	selectEntry(selection, entries, "c")
+	assert.Equal(t, []string{"a", "c", "d", "e"}, selection.VideoIDs)
+	assert.False(t, selection.RangeMode)
+
+	invertSelection(selection, entries)
+	assert.Equal(t, []string{"b"}, selection.VideoIDs)
+
+	playlist := selectedEntries(&youtube.Playlist{Title: "p", Videos: entries}, selection)
+	assert.Equal(t, "p", playlist.Title)
+	if assert.Len(t, playlist.Videos, 1) {
+		assert.Equal(t, "b", playlist.Videos[0].ID)
+	}
+}
@@ -0,0 +1,163 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/kkdai/youtube/v2"
+	"log"
+	"strings"
+	"time"
+	"youtube_downloader/internal/bot/tg/send"
+	database_client "youtube_downloader/internal/database-client"
+	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+	"youtube_downloader/internal/store"
+)
+
+// options of settings, the first option of every setting is the default one
+const (
+	ModeAsk   = "ask"   // send a keyboard with formats
+	ModeAudio = "audio" // download the best audio
+	ModeVideo = "video" // download the best video up to the max resolution
+	ModeFit   = "fit"   // download the best video up to the max resolution which Telegram accepts
+
+	CodecAAC  = "aac"
+	CodecOpus = "opus"
+
+	CaptionFileName  = "fileName"
+	CaptionTitle     = "title"
+	CaptionTitleLink = "titleLink"
+	CaptionNone      = "none"
+)
+
+var (
+	Modes       = []string{ModeAsk, ModeAudio, ModeVideo, ModeFit}
+	MaxHeights  = []int{0, 1080, 720, 480, 360}
+	AudioCodecs = []string{CodecAAC, CodecOpus}
+	Captions    = []string{CaptionFileName, CaptionTitle, CaptionTitleLink, CaptionNone}
+)
+
+// codecMimeTypes are mime types of audio formats by codecs
+var codecMimeTypes = map[string]string{
+	CodecAAC:  "audio/mp4",
+	CodecOpus: "audio/webm",
+}
+
+// DefaultSettings return settings of the user who didn't change them
+func DefaultSettings(userID int64) *store.Settings {
+	return &store.Settings{
+		UserID:     userID,
+		Mode:       ModeAsk,
+		MaxHeight:  MaxHeights[0],
+		AudioCodec: CodecAAC,
+		Caption:    CaptionFileName,
+	}
+}
+
+// userSettings return settings of the user or the default ones if they can't be loaded
+func (yh *YoutubeHandler) userSettings(userID int64) *store.Settings {
+	return LoadSettings(yh.Store, userID)
+}
+
+// LoadSettings return settings of the user from the store or the default ones if they weren't changed
+func LoadSettings(st *store.Store, userID int64) *store.Settings {
+	if st == nil {
+		return DefaultSettings(userID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	settings, err := st.Settings(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, store.ErrSettingsNotFound) {
+			log.Printf("can't get settings of user %d: %s", userID, err)
+		}
+		return DefaultSettings(userID)
+	}
+	return settings
+}
+
+// formatPreference return the preference of a format downloaded by the mode of settings
+func formatPreference(settings *store.Settings) youtube_downloader.FormatPreference {
+	return youtube_downloader.FormatPreference{
+		Audio:         settings.Mode == ModeAudio,
+		AudioMimeType: codecMimeTypes[settings.AudioCodec],
+		MaxHeight:     settings.MaxHeight,
+		Fit:           settings.Mode == ModeFit,
+	}
+}
+
+// caption return the caption of the sent file by the caption style of settings
+func caption(settings *store.Settings, path string, title string, link string) string {
+	switch settings.Caption {
+	case CaptionNone:
+		return ""
+	case CaptionTitle, CaptionTitleLink:
+		if title == "" {
+			return send.FileNameCaption(path)
+		}
+		if settings.Caption == CaptionTitleLink && link != "" {
+			return title + "\n" + link
+		}
+		return title
+	default:
+		return send.FileNameCaption(path)
+	}
+}
+
+// fileOptions return the way the file is sent to the user by the user's settings
+func (yh *YoutubeHandler) fileOptions(userID int64, path string, title string, link string) send.FileOptions {
+	settings := yh.userSettings(userID)
+	return send.FileOptions{AsDocument: settings.AsDocument, Caption: caption(settings, path, title, link)}
+}
+
+// isVideoLink return true if the link is a link of a single video, not of a stream, a channel or a playlist
+func isVideoLink(link string) bool {
+	return !strings.HasPrefix(link, "https://www.youtube.com/live/") &&
+		!youtube_downloader.IsChannelURL(link) &&
+		!isYoutubeMusicPlaylist(link) &&
+		!strings.HasPrefix(link, "https://youtube.com/playlist?")
+}
+
+// downloadWithSettings queues a download of the video by the default mode of the user instead of sending a keyboard.
+// It return false if the video should be asked about, i.e. it's a live stream or no format matches settings
+func (yh *YoutubeHandler) downloadWithSettings(message *tgbotapi.Message, link string, settings *store.Settings,
+	bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) (bool, error) {
+
+	video, err := youtube_downloader.NewYouTubeDownloader().FreshVideo(link)
+	if err != nil {
+		return false, err
+	}
+	if youtube_downloader.IsLive(video) {
+		return false, nil
+	}
+
+	format, err := youtube_downloader.SelectFormat(video, formatPreference(settings))
+	if err != nil {
+		log.Printf("SelectFormat by settings of user %d: %s", settings.UserID, err)
+		return false, nil
+	}
+
+	yh.enqueueFormat(message, video, format, bot, client, translations)
+	return true, nil
+}
+
+// enqueueFormat queues a download of the format of the video requested by the message
+func (yh *YoutubeHandler) enqueueFormat(message *tgbotapi.Message, video *youtube.Video, format youtube.Format,
+	bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) {
+
+	callbackQuery := &tgbotapi.CallbackQuery{From: message.From, Message: message}
+	if !checkTraffic(client, callbackQuery, &format) {
+		trafficLimit := (*translations)["trafficLimit"]
+		send.SendReplyMessage(bot, message, &trafficLimit)
+		return
+	}
+
+	fileKey := send.FileKey(video.ID, format.ItagNo)
+	videoURL := fmt.Sprintf(videoURLFormat, video.ID)
+	record := store.Job{Kind: kindFormat, URL: videoURL, Itag: format.ItagNo, Title: video.Title}
+	if err := yh.enqueue(bot, callbackQuery, translations, record,
+		yh.downloadAndSend(bot, callbackQuery, client, translations, record, fileKey, downloadItag(videoURL, format.ItagNo))); err != nil {
+		log.Printf("can't enqueue job: %s", err)
+	}
+}
@@ -0,0 +1,33 @@
+package youtube
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"youtube_downloader/internal/store"
+)
+
+func TestCaption(t *testing.T) {
+	settings := DefaultSettings(1)
+	link := "https://www.youtube.com/watch?v=id"
+
+	assert.Equal(t, "video.mp4", caption(settings, "/tmp/video.mp4", "Title", link))
+
+	settings.Caption = CaptionTitle
+	assert.Equal(t, "Title", caption(settings, "/tmp/video.mp4", "Title", link))
+	assert.Equal(t, "video.mp4", caption(settings, "/tmp/video.mp4", "", link))
+
+	settings.Caption = CaptionTitleLink
+	assert.Equal(t, "Title\n"+link, caption(settings, "/tmp/video.mp4", "Title", link))
+
+	settings.Caption = CaptionNone
+	assert.Empty(t, caption(settings, "/tmp/video.mp4", "Title", link))
+}
+
+func TestFormatPreference(t *testing.T) {
+	settings := &store.Settings{Mode: ModeFit, MaxHeight: 720, AudioCodec: CodecOpus}
+	pref := formatPreference(settings)
+	assert.False(t, pref.Audio)
+	assert.True(t, pref.Fit)
+	assert.Equal(t, 720, pref.MaxHeight)
+	assert.Equal(t, "audio/webm", pref.AudioMimeType)
+}
@@ -1,27 +1,48 @@
 package youtube
 
 import (
+	"context"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
+	"youtube_downloader/internal/bot/tg/send"
+	database_client "youtube_downloader/internal/database-client"
 	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/queue"
+	"youtube_downloader/internal/store"
 )
 
-// handleYoutubeStream transforms live/ link into common video link
-// creates a keyboard and return it
-func (yh *YoutubeHandler) handleYoutubeStream(message *tgbotapi.Message) (*tgbotapi.InlineKeyboardMarkup, error) {
+const (
+	recordProgressInterval = 10 * time.Second
+)
+
+// recordDurations are the durations of a live stream recording offered to the user, 0 means until the stream ends
+var recordDurations = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour, 0}
+
+// handleYoutubeStream transforms live/ link into common video link,
+// creates a keyboard with recording durations for an ongoing stream
+// or a keyboard with formats for a finished one and return it
+func (yh *YoutubeHandler) handleYoutubeStream(videoURLWithLivePrefix string, b *buttons) (*tgbotapi.InlineKeyboardMarkup, error) {
 
-	videoURLWithLivePrefix := message.Text
 	videoURL := FormatYouTubeURLOnStream(videoURLWithLivePrefix)
-	formats, err := youtube_downloader.FormatWithAudioChannels(videoURL)
+	video, err := youtube_downloader.NewYouTubeDownloader().FreshVideo(videoURL)
 	if err != nil {
-		log.Printf("FormatWithAudioChannels return %s", err)
+		log.Printf("GetVideo in handleYoutubeStream return %s", err)
 		return nil, err
 	}
 
-	keyboard, err := getKeyboardVideoFormats(&formats, &videoURL)
+	if youtube_downloader.IsLive(video) {
+		keyboard := getKeyboardRecording(videoURL, b)
+		return &keyboard, nil
+	}
+
+	formats := video.Formats.WithAudioChannels()
+	keyboard, err := getKeyboardVideoFormats(&formats, videoURL, video.ID, b)
 	if err != nil {
 		log.Printf("GetKeyboard return %s", err)
 		return nil, err
@@ -30,6 +51,94 @@ func (yh *YoutubeHandler) handleYoutubeStream(message *tgbotapi.Message) (*tgbot
 	return keyboard, nil
 }
 
+// getKeyboardRecording return a keyboard with durations of a live stream recording.
+// Button's payload include video's url and the duration in secs
+func getKeyboardRecording(videoURL string, b *buttons) tgbotapi.InlineKeyboardMarkup {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup()
+	for _, duration := range recordDurations {
+		text := locale.Text(b.translations, "recordMinutes", locale.Params{"minutes": int(duration.Minutes())})
+		if duration == 0 {
+			text = b.translations["recordUntilEnd"]
+		}
+		button := b.button(text,
+			store.Callback{Action: actionRecord, URL: videoURL, Value: strconv.Itoa(int(duration.Seconds()))})
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{button})
+	}
+	return keyboard
+}
+
+// HandleCallbackQueryWithRecording records the live stream for the duration from the button's payload,
+// shows the recording progress and sends the recording
+func (yh *YoutubeHandler) HandleCallbackQueryWithRecording(callbackQuery *tgbotapi.CallbackQuery, callback *store.Callback,
+	bot *tgbotapi.BotAPI, client *database_client.Client, translations *map[string]string) {
+
+	videoURL := callback.URL
+	secs, err := strconv.Atoi(callback.Value)
+	if err != nil {
+		log.Printf("can't parse record duration: %s", err)
+		return
+	}
+
+	if !hasTraffic(client, callbackQuery, 0) {
+		trafficLimit := (*translations)["trafficLimit"]
+		send.SendReplyMessage(bot, callbackQuery.Message, &trafficLimit)
+		return
+	}
+
+	dl := youtube_downloader.NewYouTubeDownloader()
+	video, err := dl.FreshVideo(videoURL)
+	if err != nil {
+		log.Printf("can't get video in HandleCallbackQueryWithRecording: %s", err)
+		somethingWentWrong := (*translations)["somethingWentWrong"]
+		send.SendReplyMessage(bot, callbackQuery.Message, &somethingWentWrong)
+		return
+	}
+	if !youtube_downloader.IsLive(video) {
+		streamEnded := (*translations)["streamEnded"]
+		send.SendReplyMessage(bot, callbackQuery.Message, &streamEnded)
+		return
+	}
+
+	record := store.Job{Kind: kindRecording, URL: videoURL, Title: video.Title}
+	err = yh.enqueue(bot, callbackQuery, translations, record, func(ctx context.Context, job *queue.Job, resp *tgbotapi.Message) error {
+		recordingNotification := (*translations)["recordingNotification"]
+		if err := send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &recordingNotification); err != nil {
+			log.Printf("can't send edit message: %s", err.Error())
+		}
+
+		duration := time.Duration(secs) * time.Second
+		lastUpdate := time.Now()
+		onProgress := func(progress youtube_downloader.RecordProgress) {
+			if duration > 0 {
+				job.SetProgress(float64(progress.Elapsed) * 100 / float64(duration))
+			}
+			if time.Since(lastUpdate) < recordProgressInterval {
+				return
+			}
+			lastUpdate = time.Now()
+			text := recordingNotification + "\n" + locale.Text(*translations, "recordingProgress", locale.Params{
+				"elapsed": progress.Elapsed.Truncate(time.Second),
+				"size":    strconv.FormatFloat(float64(progress.Size)/(1024*1024), 'f', 1, 64)})
+			if err := send.SendEditMessage(bot, resp.Chat.ID, resp.MessageID, &text); err != nil {
+				log.Printf("can't send edit message: %s", err.Error())
+			}
+		}
+
+		job.SetState(queue.StateDownloading)
+		path, err := dl.RecordLiveStream(ctx, video, duration, onProgress)
+		if err != nil {
+			return fmt.Errorf("RecordLiveStream error: %w", err)
+		}
+
+		job.SetState(queue.StateUploading)
+		fileSize := fileSizeMb(path)
+		return yh.sendAnswer(bot, callbackQuery, resp, &path, "", video.Title, videoURL, client, &fileSize, translations)
+	})
+	if err != nil {
+		log.Printf("can't enqueue job: %s", err)
+	}
+}
+
 // FormatYouTubeURLOnStream instead of live/ links return link on video
 func FormatYouTubeURLOnStream(inputURL string) string {
 	u, err := url.Parse(inputURL)
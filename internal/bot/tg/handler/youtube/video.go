@@ -7,16 +7,23 @@ import (
 )
 
 // handleYoutubeVideo gets all possible formats of the video by a link,
-// creates a keyboard and return it
-func (yh *YoutubeHandler) handleYoutubeVideo(message *Message) (*InlineKeyboardMarkup, error) {
-	videoURL := message.Text
-	formats, err := youtube_downloader.FormatWithAudioChannelsComposite(videoURL)
+// creates a keyboard and return it.
+// For an ongoing live stream it returns a keyboard with recording durations
+func (yh *YoutubeHandler) handleYoutubeVideo(videoURL string, b *buttons) (*InlineKeyboardMarkup, error) {
+	video, err := youtube_downloader.NewYouTubeDownloader().FreshVideo(videoURL)
 	if err != nil {
-		log.Printf("FormatWithAudioChannels return %s", err)
+		log.Printf("GetVideo in handleYoutubeVideo return %s", err)
 		return nil, err
 	}
 
-	keyboard, err := getKeyboardVideoFormats(&formats, &videoURL)
+	if youtube_downloader.IsLive(video) {
+		keyboard := getKeyboardRecording(videoURL, b)
+		return &keyboard, nil
+	}
+
+	formats := youtube_downloader.UniqueFormats(video)
+
+	keyboard, err := getKeyboardVideoFormats(&formats, videoURL, video.ID, b)
 	if err != nil {
 		log.Printf("GetKeyboard return %s", err)
 		return nil, err
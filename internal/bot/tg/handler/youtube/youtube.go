@@ -7,7 +7,11 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	database_client "youtube_downloader/internal/database-client"
 	youtube_downloader "youtube_downloader/internal/downloader/youtube"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/queue"
+	"youtube_downloader/internal/store"
 )
 
 const (
@@ -16,40 +20,65 @@ const (
 	TrafficLimit = 5000.0 // Mb
 )
 
-// YoutubeHandler is a service for downloading video from youtube
+// YoutubeHandler is a service for downloading video from youtube.
+// Every request gets a new downloader, so requests rotate through the pool of proxies
 type YoutubeHandler struct {
-	Downloader youtube_downloader.YouTubeDownloader
+	Queue *queue.Queue // downloads run in the queue, so they don't block handling of updates
+	Store *store.Store // jobs are saved to resume them after a restart and payloads of buttons are kept in it
 }
 
-// NewYoutubeHandler return new YoutubeHandler
-func NewYoutubeHandler() *YoutubeHandler {
-	downloader := youtube_downloader.NewYouTubeDownloader()
+// NewYoutubeHandler return new YoutubeHandler which runs downloads in the queue and saves them in the store
+func NewYoutubeHandler(jobs *queue.Queue, st *store.Store) *YoutubeHandler {
 	return &YoutubeHandler{
-		Downloader: *downloader,
+		Queue: jobs,
+		Store: st,
 	}
 }
 
-// HandleMessage handle YouTube link and return error
-func (yh *YoutubeHandler) HandleMessage(message *tgbotapi.Message) (*tgbotapi.InlineKeyboardMarkup, error) {
-	return yh.handleYoutubeLink(message)
+// HandleMessage handle YouTube link from the message and return error.
+// If the sender set a default mode, a video is queued at once and no keyboard is returned.
+// Buttons of the keyboard can be pressed only by the sender of the message
+func (yh *YoutubeHandler) HandleMessage(message *tgbotapi.Message, link string, bot *tgbotapi.BotAPI,
+	client *database_client.Client, translations *map[string]string) (*tgbotapi.InlineKeyboardMarkup, error) {
+	var requesterID int64
+	if message.From != nil {
+		requesterID = message.From.ID
+		if settings := yh.userSettings(requesterID); settings.Mode != ModeAsk && isVideoLink(link) {
+			queued, err := yh.downloadWithSettings(message, link, settings, bot, client, translations)
+			if queued || err != nil {
+				return nil, err
+			}
+		}
+	}
+	b := newButtons(requesterID, translations)
+	keyboard, err := yh.handleYoutubeLink(link, b)
+	if err != nil {
+		return nil, err
+	}
+	if err := yh.saveButtons(b); err != nil {
+		return nil, fmt.Errorf("can't save buttons: %w", err)
+	}
+	return keyboard, nil
 }
 
 // handleYoutubeLink checks the link type and calls the appropriate method
-func (yh *YoutubeHandler) handleYoutubeLink(message *tgbotapi.Message) (*tgbotapi.InlineKeyboardMarkup, error) {
-
-	videoURL := message.Text
+func (yh *YoutubeHandler) handleYoutubeLink(videoURL string, b *buttons) (*tgbotapi.InlineKeyboardMarkup, error) {
 	switch {
 	case strings.HasPrefix(videoURL, "https://www.youtube.com/live/"):
-		return yh.handleYoutubeStream(message)
+		return yh.handleYoutubeStream(videoURL, b)
+	case youtube_downloader.IsChannelURL(videoURL):
+		return yh.handleYoutubeChannel(videoURL, b)
+	case isYoutubeMusicPlaylist(videoURL):
+		return yh.handleYoutubeMusic(videoURL, b)
 	case strings.HasPrefix(videoURL, "https://youtube.com/playlist?"):
-		return yh.handleYoutubePlaylist(message)
+		return yh.handleYoutubePlaylist(videoURL, b)
 	default:
-		return yh.handleYoutubeVideo(message)
+		return yh.handleYoutubeVideo(videoURL, b)
 	}
 }
 
-// getKeyboard return InlineKeyboardMarkup by all possible video formats. Button's data include video's url and ItagNo
-func getKeyboardVideoFormats(formats *youtube.FormatList, url *string) (*tgbotapi.InlineKeyboardMarkup, error) {
+// getKeyboard return InlineKeyboardMarkup by all possible video formats. Button's payload include video's url and ItagNo
+func getKeyboardVideoFormats(formats *youtube.FormatList, videoURL string, videoID string, b *buttons) (*tgbotapi.InlineKeyboardMarkup, error) {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup()
 
 	// getting the size of audio
@@ -73,7 +102,6 @@ func getKeyboardVideoFormats(formats *youtube.FormatList, url *string) (*tgbotap
 		}
 
 		videoFormat := strings.Split(format.MimeType, ";")[0]
-		data := fmt.Sprintf("%s,%s", *url, strconv.Itoa(format.ItagNo))
 
 		size, err := getFileSize(format)
 		size = size / (1024 * 1024)
@@ -91,11 +119,11 @@ func getKeyboardVideoFormats(formats *youtube.FormatList, url *string) (*tgbotap
 		if format.QualityLabel != "" {
 			sign = append(sign, format.QualityLabel)
 		}
-		sign = append(sign, strconv.FormatFloat(size, 'f', 2, 64))
+		text := locale.Text(b.translations, "formatButton",
+			locale.Params{"format": strings.Join(sign, ", "), "size": strconv.FormatFloat(size, 'f', 2, 64)})
 
-		button := tgbotapi.NewInlineKeyboardButtonData(
-			fmt.Sprintf("%s Mb", strings.Join(sign, ", ")),
-			data)
+		button := b.button(text,
+			store.Callback{Action: actionFormat, URL: videoURL, VideoID: videoID, Itag: format.ItagNo})
 		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []tgbotapi.InlineKeyboardButton{button})
 	}
 
@@ -0,0 +1,70 @@
+package tg
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// extractLinks returns every distinct YouTube link found in the message:
+// in its text and caption, in url entities and behind text_link entities.
+// Forwarded posts carry the same fields, so they are handled the same way.
+func extractLinks(message *tgbotapi.Message) []string {
+	if message == nil {
+		return nil
+	}
+
+	var candidates []string
+	candidates = append(candidates, linksFromEntities(message.Text, message.Entities)...)
+	candidates = append(candidates, linksFromEntities(message.Caption, message.CaptionEntities)...)
+	candidates = append(candidates, urlPattern.FindAllString(message.Text, -1)...)
+	candidates = append(candidates, urlPattern.FindAllString(message.Caption, -1)...)
+
+	var links []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		link := normalizeLink(candidate)
+		if !isYoutubeLink(link) || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+// linksFromEntities returns links of url and text_link entities.
+// Entity offsets are counted in UTF-16 code units, so the text is converted before slicing.
+func linksFromEntities(text string, entities []tgbotapi.MessageEntity) []string {
+	var links []string
+	encoded := utf16.Encode([]rune(text))
+	for _, entity := range entities {
+		switch {
+		case entity.IsTextLink():
+			links = append(links, entity.URL)
+		case entity.IsURL():
+			end := entity.Offset + entity.Length
+			if entity.Offset < 0 || end > len(encoded) {
+				continue
+			}
+			links = append(links, string(utf16.Decode(encoded[entity.Offset:end])))
+		}
+	}
+	return links
+}
+
+// normalizeLink trims trailing punctuation and brings a link to the https:// form
+func normalizeLink(link string) string {
+	link = strings.TrimSpace(link)
+	link = strings.TrimRight(link, ".,;:!?)]}'\"")
+	switch {
+	case strings.HasPrefix(link, "http://"):
+		link = "https://" + strings.TrimPrefix(link, "http://")
+	case !strings.HasPrefix(link, "https://"):
+		link = "https://" + link
+	}
+	return link
+}
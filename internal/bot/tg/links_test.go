@@ -0,0 +1,61 @@
+package tg
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type LinksTestCase struct {
+	name          string
+	message       *tgbotapi.Message
+	expectedLinks []string
+}
+
+var linksTestCases = []LinksTestCase{
+	{
+		name:          "Plain link",
+		message:       &tgbotapi.Message{Text: "https://youtu.be/wPdX66-Ag2s"},
+		expectedLinks: []string{"https://youtu.be/wPdX66-Ag2s"},
+	},
+	{
+		name:          "Several links with duplicates",
+		message:       &tgbotapi.Message{Text: "look https://youtu.be/wPdX66-Ag2s, https://youtu.be/HkJezWe8naI and https://youtu.be/wPdX66-Ag2s"},
+		expectedLinks: []string{"https://youtu.be/wPdX66-Ag2s", "https://youtu.be/HkJezWe8naI"},
+	},
+	{
+		name: "Text link and url entity after emoji",
+		message: &tgbotapi.Message{
+			Text: "🔥 watch youtube.com/watch?v=HkJezWe8naI",
+			Entities: []tgbotapi.MessageEntity{
+				{Type: "text_link", Offset: 3, Length: 5, URL: "https://www.youtube.com/watch?v=wPdX66-Ag2s"},
+				{Type: "url", Offset: 9, Length: 31},
+			},
+		},
+		expectedLinks: []string{"https://www.youtube.com/watch?v=wPdX66-Ag2s", "https://youtube.com/watch?v=HkJezWe8naI"},
+	},
+	{
+		name: "Forwarded post with caption",
+		message: &tgbotapi.Message{
+			ForwardFromChat: &tgbotapi.Chat{Type: "channel"},
+			Caption:         "new video",
+			CaptionEntities: []tgbotapi.MessageEntity{
+				{Type: "text_link", Offset: 0, Length: 9, URL: "https://youtu.be/wPdX66-Ag2s"},
+			},
+		},
+		expectedLinks: []string{"https://youtu.be/wPdX66-Ag2s"},
+	},
+	{
+		name:          "No youtube links",
+		message:       &tgbotapi.Message{Text: "hello https://example.com"},
+		expectedLinks: nil,
+	},
+}
+
+func TestExtractLinks(t *testing.T) {
+	for _, tc := range linksTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedLinks, extractLinks(tc.message))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// startMetrics starts an HTTP server exposing metrics at /debug/vars on METRICS_ADDR, e.g. 127.0.0.1:9090.
+// Metrics aren't served if METRICS_ADDR is empty
+func (tb *TgBot) startMetrics() {
+	addr := os.Getenv("METRICS_ADDR")
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	tb.metricsServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := tb.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics server error: %s", err)
+		}
+	}()
+	log.Printf("Serving metrics at %s/debug/vars", addr)
+}
+
+// stopMetrics stops the metrics server if it's running
+func (tb *TgBot) stopMetrics() {
+	if tb.metricsServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tb.metricsServer.Shutdown(ctx); err != nil {
+		log.Printf("can't stop metrics server: %s", err)
+	}
+}
@@ -0,0 +1,126 @@
+package tg
+
+import (
+	"context"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"youtube_downloader/internal/bot/tg/send"
+	"youtube_downloader/internal/locale"
+	"youtube_downloader/internal/ratelimit"
+)
+
+// subscription statuses of users are their rate limit tiers
+const (
+	tierInactive = "inactive"
+	tierActive   = "active"
+)
+
+const tierTTL = 10 * time.Minute // subscription statuses are requested from the database at most this often
+
+var (
+	defaultGlobalLimit = ratelimit.Limit{Requests: 30, Period: time.Second} // Telegram allows ~30 messages per second
+	defaultTierLimits  = map[string]ratelimit.Limit{
+		tierInactive: {Requests: 10, Period: time.Minute},
+		tierActive:   {Requests: 30, Period: time.Minute},
+	}
+)
+
+// tierCache keeps subscription statuses of users, so the database isn't requested on every update
+type tierCache struct {
+	mu    sync.Mutex
+	tiers map[int64]cachedTier
+}
+
+type cachedTier struct {
+	tier    string
+	expires time.Time
+}
+
+// newLimiter return a limiter with limits from RATE_LIMIT_GLOBAL and RATE_LIMIT_<TIER>, e.g. RATE_LIMIT_ACTIVE=30/1m
+func newLimiter() *ratelimit.Limiter {
+	global := envLimit("RATE_LIMIT_GLOBAL", defaultGlobalLimit)
+	tiers := make(map[string]ratelimit.Limit)
+	for tier, limit := range defaultTierLimits {
+		tiers[tier] = envLimit("RATE_LIMIT_"+strings.ToUpper(tier), limit)
+	}
+	return ratelimit.New(global, tiers, tierInactive)
+}
+
+// envLimit return the limit from the environment variable or def if it isn't set or invalid
+func envLimit(name string, def ratelimit.Limit) ratelimit.Limit {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		log.Printf("%s: %s, %d/%s is used", name, err, def.Requests, def.Period)
+		return def
+	}
+	return limit
+}
+
+// allowUpdate checks the rate limit of the user who sent the update and tells a throttled user how long to wait
+func (tb *TgBot) allowUpdate(ctx context.Context, update tgbotapi.Update, user *tgbotapi.User) bool {
+	wait := tb.limiter.Allow(user.ID, tb.userTier(ctx, user))
+	if wait == 0 {
+		return true
+	}
+	log.Printf("[%s] is rate limited for %s", user.UserName, wait)
+
+	// an inline query is sent on every typed character, so it's ignored silently
+	if update.InlineQuery != nil || !tb.limiter.ShouldNotify(user.ID, wait) {
+		return false
+	}
+
+	translations := tb.translations(user.LanguageCode)
+	rateLimited := locale.Text(translations, "rateLimited", locale.Params{"seconds": int(math.Ceil(wait.Seconds()))})
+	if update.CallbackQuery != nil {
+		if err := send.SendCallbackAnswer(tb.Bot, update.CallbackQuery.ID, rateLimited); err != nil {
+			log.Printf("can't answer callback query: %s", err)
+		}
+		return false
+	}
+	if _, err := send.SendReplyMessage(tb.Bot, update.Message, &rateLimited); err != nil {
+		log.Printf("can't send reply message: %s", err)
+	}
+	return false
+}
+
+// userTier return the subscription status of the user, it's cached for tierTTL.
+// Users whose status can't be got are limited as inactive ones
+func (tb *TgBot) userTier(ctx context.Context, user *tgbotapi.User) string {
+	tb.tiers.mu.Lock()
+	cached, ok := tb.tiers.tiers[user.ID]
+	tb.tiers.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.tier
+	}
+
+	tier := tierInactive
+	if user.UserName != "" {
+		status, err := tb.Client.GetSubscriptionStatus(ctx, user.UserName)
+		if err != nil {
+			log.Printf("can't get subscription status of %s: %s", user.UserName, err)
+		} else if status == tierActive {
+			tier = tierActive
+		}
+	}
+
+	tb.tiers.mu.Lock()
+	tb.tiers.tiers[user.ID] = cachedTier{tier: tier, expires: time.Now().Add(tierTTL)}
+	tb.tiers.mu.Unlock()
+	return tier
+}
+
+// forgetTier removes the cached subscription status of the user, i.e. after a payment
+func (tb *TgBot) forgetTier(userID int64) {
+	tb.tiers.mu.Lock()
+	defer tb.tiers.mu.Unlock()
+	delete(tb.tiers.tiers, userID)
+}
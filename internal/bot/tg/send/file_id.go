@@ -0,0 +1,49 @@
+package send
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"sync"
+)
+
+// fileIDs keeps file_id of sent files, so the same file can be sent again without uploading
+var fileIDs = struct {
+	sync.RWMutex
+	ids map[string]string
+}{ids: make(map[string]string)}
+
+// FileKey return a key of a file downloaded from the video with the format
+func FileKey(videoID string, itagNo int) string {
+	return fmt.Sprintf("%s:%d", videoID, itagNo)
+}
+
+// CachedFileID return file_id of a file sent before by its key
+func CachedFileID(key string) (string, bool) {
+	fileIDs.RLock()
+	defer fileIDs.RUnlock()
+	fileID, ok := fileIDs.ids[key]
+	return fileID, ok
+}
+
+// rememberFileID saves file_id of the sent video or audio by the key
+func rememberFileID(key string, sent tgbotapi.Message) {
+	if key == "" {
+		return
+	}
+
+	var fileID string
+	switch {
+	case sent.Video != nil:
+		fileID = sent.Video.FileID
+	case sent.Audio != nil:
+		fileID = sent.Audio.FileID
+	case sent.Document != nil:
+		fileID = sent.Document.FileID
+	default:
+		return
+	}
+
+	fileIDs.Lock()
+	defer fileIDs.Unlock()
+	fileIDs.ids[key] = fileID
+}
@@ -2,8 +2,10 @@ package send
 
 import (
 	"errors"
+	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -11,71 +13,146 @@ import (
 	youtube_downloader "youtube_downloader/internal/downloader/youtube"
 )
 
-// sendFile send file according its type
-func SendFile(bot *tgbotapi.BotAPI, message *tgbotapi.Message, filePath string) error {
+// Errors of sending a file, SendFile wraps errors of Telegram with them
+var (
+	ErrTooLarge     = errors.New("file is too large for Telegram")
+	ErrUploadFailed = errors.New("upload failed")
+)
 
-	switch filepath.Ext(filePath) {
-	case ".mp4":
-		return sendVideo(bot, message.Chat.ID, message.MessageID, filePath)
-	case ".weba", ".mp3", ".m4a":
-		return sendAudio(bot, message.Chat.ID, message.MessageID, filePath)
-	default:
+// FileOptions are the way a file is sent
+type FileOptions struct {
+	AsDocument bool   // the file is sent as a document, so Telegram doesn't compress it
+	Caption    string // it may be empty
+}
+
+// SendFile send file according its type.
+// If fileKey isn't empty, file_id of the sent file is cached by it.
+// It return ErrTooLarge if Telegram refused the file because of its size and ErrUploadFailed for other failures
+func SendFile(bot *tgbotapi.BotAPI, message *tgbotapi.Message, filePath string, fileKey string, options FileOptions) error {
+	return uploadError(sendFile(bot, message, filePath, fileKey, options))
+}
+
+// sendFile send file according its type and caches its file_id by fileKey
+func sendFile(bot *tgbotapi.BotAPI, message *tgbotapi.Message, filePath string, fileKey string, options FileOptions) error {
+
+	var sent tgbotapi.Message
+	var err error
+	switch ext := filepath.Ext(filePath); {
+	case ext != ".mp4" && ext != ".weba" && ext != ".mp3" && ext != ".m4a":
 		return errors.New("unknown extension")
+	case options.AsDocument:
+		if ext != ".mp4" {
+			if err := restoreAudioExtension(filePath); err != nil {
+				return err
+			}
+		}
+		// file_id of a document can't be sent as a video or an audio, so it isn't cached
+		_, err = sendDocument(bot, message.Chat.ID, message.MessageID, filePath, options.Caption)
+		return err
+	case ext == ".mp4":
+		sent, err = sendVideo(bot, message.Chat.ID, message.MessageID, filePath, options.Caption)
+	default:
+		sent, err = sendAudio(bot, message.Chat.ID, message.MessageID, filePath, options.Caption)
 	}
+	if err != nil {
+		return err
+	}
+
+	rememberFileID(fileKey, sent)
+	return nil
+}
+
+// uploadError wraps the error of sending a file with ErrTooLarge or ErrUploadFailed
+func uploadError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var tgErr *tgbotapi.Error
+	if errors.As(err, &tgErr) && (tgErr.Code == http.StatusRequestEntityTooLarge || tgErr.Message == "Request Entity Too Large") {
+		return fmt.Errorf("%w: %w", ErrTooLarge, err)
+	}
+	return fmt.Errorf("%w: %w", ErrUploadFailed, err)
+}
+
+// FileNameCaption return the name of the file as its caption
+func FileNameCaption(filePath string) string {
+	return path.Base(filePath)
 }
 
 // sendVideo sends to user video by chatID and MessageID
-func sendVideo(bot *tgbotapi.BotAPI, chatID int64, MessageID int, filePath string) error {
+func sendVideo(bot *tgbotapi.BotAPI, chatID int64, MessageID int, filePath string, caption string) (tgbotapi.Message, error) {
 
 	log.Print("Start sending: " + filePath)
 
 	video := tgbotapi.NewVideo(chatID, tgbotapi.FilePath(filePath))
 	video.ReplyToMessageID = MessageID
+	video.Caption = caption
 
-	videoName := path.Base(filePath)
-	video.Caption = videoName
-
-	_, err := bot.Send(video)
+	sent, err := bot.Send(video)
 	if err != nil {
 		log.Printf("Can't send file: %s", err.Error())
-		return err
+		return sent, err
 	}
 	log.Print("Video has sent!")
-	return err
+	return sent, err
 }
 
 // sendAudio sends to user audio by chatID and MessageID
-func sendAudio(bot *tgbotapi.BotAPI, chatID int64, MessageID int, filePath string) error {
+func sendAudio(bot *tgbotapi.BotAPI, chatID int64, MessageID int, filePath string, caption string) (tgbotapi.Message, error) {
 
 	log.Print("Start sending: " + filePath)
 
-	// in docker container audio files downloading with .mov extension(I don't know why),
-	// so if it is true, we changed the extension on original
-	if !fileExists(filePath) {
-		log.Printf("sendAudio: filo by file pat not exist: %s", filePath)
-		fileExtension := filepath.Ext(filePath)
-		tmpFilePath := strings.TrimSuffix(filePath, fileExtension) + ".mov"
-
-		if err := youtube_downloader.ChangeFileExtension(tmpFilePath, fileExtension); err != nil {
-			log.Printf("Can't change extension for: %s", tmpFilePath)
-			return err
-		}
-
+	if err := restoreAudioExtension(filePath); err != nil {
+		return tgbotapi.Message{}, err
 	}
 
 	audio := tgbotapi.NewAudio(chatID, tgbotapi.FilePath(filePath))
 	audio.ReplyToMessageID = MessageID
+	audio.Caption = caption
 
-	audioName := path.Base(filePath)
-	audio.Caption = audioName
-
-	_, err := bot.Send(audio)
+	sent, err := bot.Send(audio)
 	if err != nil {
 		log.Printf("Can't send file: %s", err.Error())
-		return err
+		return sent, err
 	}
 	log.Print("Audio has sent!")
-	return err
+	return sent, err
+}
+
+// restoreAudioExtension renames the audio file downloaded with .mov extension back.
+// In docker container audio files downloading with .mov extension(I don't know why),
+// so if it is true, we changed the extension on original
+func restoreAudioExtension(filePath string) error {
+	if fileExists(filePath) {
+		return nil
+	}
+	log.Printf("sendAudio: filo by file pat not exist: %s", filePath)
+	fileExtension := filepath.Ext(filePath)
+	tmpFilePath := strings.TrimSuffix(filePath, fileExtension) + ".mov"
+
+	if err := youtube_downloader.ChangeFileExtension(tmpFilePath, fileExtension); err != nil {
+		log.Printf("Can't change extension for: %s", tmpFilePath)
+		return err
+	}
+	return nil
+}
+
+// sendDocument sends to user the file as a document by chatID and MessageID
+func sendDocument(bot *tgbotapi.BotAPI, chatID int64, MessageID int, filePath string, caption string) (tgbotapi.Message, error) {
+
+	log.Print("Start sending: " + filePath)
+
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filePath))
+	document.ReplyToMessageID = MessageID
+	document.Caption = caption
+
+	sent, err := bot.Send(document)
+	if err != nil {
+		log.Printf("Can't send file: %s", err.Error())
+		return sent, err
+	}
+	log.Print("Document has sent!")
+	return sent, err
 }
 
 func fileExists(filePath string) bool {
@@ -3,6 +3,7 @@ package send
 import (
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"youtube_downloader/internal/locale"
 )
 
 // SendMessage just sends text message using BotAPI Send
@@ -27,15 +28,14 @@ func SendEditMessage(bot *tgbotapi.BotAPI, chatID int64, messageID int, text *st
 	return err
 }
 
-// SendKeyboardMessageReply sends user a keyboard in reply
+// SendKeyboardMessageReply sends user a keyboard in reply for a link
 func SendKeyboardMessageReply(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
-	keyboard *tgbotapi.InlineKeyboardMarkup, translations *map[string]string) error {
+	keyboard *tgbotapi.InlineKeyboardMarkup, link string, translations *map[string]string) error {
 
-	keyboardMessageReply := (*translations)["keyboardMessageReply"]
-	msg := tgbotapi.NewMessage(message.Chat.ID,
-		fmt.Sprintf(keyboardMessageReply, message.Text),
-	)
+	keyboardMessageReply := locale.Text(*translations, "keyboardMessageReply", locale.Params{"link": link})
+	msg := tgbotapi.NewMessage(message.Chat.ID, keyboardMessageReply)
 	msg.ReplyMarkup = keyboard
+	replyInGroup(&msg, message)
 	_, err := bot.Send(msg)
 	return err
 }
@@ -58,6 +58,36 @@ func SendKeyboardMessageReplyWithFormattedLink(bot *tgbotapi.BotAPI, message *tg
 		fmt.Sprintf("%s\n%s\n%s", yourLink, videoURL, chooseFormat),
 	)
 	msg.ReplyMarkup = keyboard
+	replyInGroup(&msg, message)
 	_, err := bot.Send(msg)
 	return err
 }
+
+// SendEditKeyboard replaces a keyboard of the message by its id
+func SendEditKeyboard(bot *tgbotapi.BotAPI, chatID int64, messageID int, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	editKeyboard := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, *keyboard)
+	_, err := bot.Send(editKeyboard)
+	return err
+}
+
+// SendEditMessageWithKeyboard replaces a text and a keyboard of the message by its id
+func SendEditMessageWithKeyboard(bot *tgbotapi.BotAPI, chatID int64, messageID int, text *string,
+	keyboard *tgbotapi.InlineKeyboardMarkup) error {
+
+	editMessage := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, *text, *keyboard)
+	_, err := bot.Send(editMessage)
+	return err
+}
+
+// SendCallbackAnswer shows the user who pressed a button a notification at the top of the chat
+func SendCallbackAnswer(bot *tgbotapi.BotAPI, callbackQueryID string, text string) error {
+	_, err := bot.Request(tgbotapi.NewCallback(callbackQueryID, text))
+	return err
+}
+
+// replyInGroup makes the message a reply to the requester's message in groups, so only the requester can press its keyboard
+func replyInGroup(msg *tgbotapi.MessageConfig, message *tgbotapi.Message) {
+	if message.Chat != nil && (message.Chat.IsGroup() || message.Chat.IsSuperGroup()) {
+		msg.ReplyToMessageID = message.MessageID
+	}
+}
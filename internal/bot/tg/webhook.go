@@ -0,0 +1,151 @@
+package tg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	modePolling = "polling"
+	modeWebhook = "webhook"
+
+	defaultWebhookListen = ":8080"
+	webhookSecretHeader  = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+// initWebhook registers the webhook at WEBHOOK_URL and starts an HTTP server at WEBHOOK_LISTEN receiving its updates.
+// Telegram sends WEBHOOK_SECRET in every request, requests without it are rejected.
+// If WEBHOOK_CERT and WEBHOOK_KEY are set, the server uses HTTPS and the certificate is uploaded to Telegram,
+// so a self-signed one can be used
+func (tb *TgBot) initWebhook() (tgbotapi.UpdatesChannel, error) {
+	webhookURL, err := url.Parse(os.Getenv("WEBHOOK_URL"))
+	if err != nil || webhookURL.Scheme != "https" || webhookURL.Host == "" {
+		return nil, fmt.Errorf("WEBHOOK_URL must be a public https url, got %q", os.Getenv("WEBHOOK_URL"))
+	}
+
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		// the webhook is registered on every start, so a random secret is enough
+		if secret, err = randomSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	listen := os.Getenv("WEBHOOK_LISTEN")
+	if listen == "" {
+		listen = defaultWebhookListen
+	}
+	certFile, keyFile := os.Getenv("WEBHOOK_CERT"), os.Getenv("WEBHOOK_KEY")
+
+	path := webhookURL.Path
+	if path == "" {
+		path = "/"
+	}
+	updates := make(chan tgbotapi.Update, tb.Bot.Buffer)
+	mux := http.NewServeMux()
+	mux.Handle(path, newWebhookHandler(tb.Bot, secret, updates))
+
+	tb.webhookServer = &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		var err error
+		if certFile != "" && keyFile != "" {
+			err = tb.webhookServer.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = tb.webhookServer.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Webhook server error: ", err)
+		}
+	}()
+
+	if err := tb.setWebhook(webhookURL.String(), secret, certFile); err != nil {
+		return nil, err
+	}
+	log.Printf("Receiving updates by the webhook %s at %s", webhookURL.Redacted(), listen)
+	return updates, nil
+}
+
+// setWebhook registers the webhook with the secret token.
+// The library's WebhookConfig has no secret_token, so the request is made by hand
+func (tb *TgBot) setWebhook(webhookURL, secret, certFile string) error {
+	params := tgbotapi.Params{
+		"url":          webhookURL,
+		"secret_token": secret,
+	}
+	if err := params.AddInterface("allowed_updates", allowedUpdates); err != nil {
+		return fmt.Errorf("could not set webhook: %w", err)
+	}
+
+	var err error
+	if certFile != "" {
+		files := []tgbotapi.RequestFile{{Name: "certificate", Data: tgbotapi.FilePath(certFile)}}
+		_, err = tb.Bot.UploadFiles("setWebhook", params, files)
+	} else {
+		_, err = tb.Bot.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return fmt.Errorf("could not set webhook: %w", err)
+	}
+	return nil
+}
+
+// stopWebhook stops the webhook server waiting for requests in progress.
+// Updates which weren't handled are sent by Telegram again after the restart
+func (tb *TgBot) stopWebhook(ctx context.Context) {
+	if err := tb.webhookServer.Shutdown(ctx); err != nil {
+		log.Printf("can't stop webhook server: %s", err)
+	}
+}
+
+// deleteWebhook removes the webhook, otherwise Telegram refuses to return updates by polling
+func (tb *TgBot) deleteWebhook() error {
+	_, err := tb.Bot.Request(tgbotapi.DeleteWebhookConfig{})
+	return err
+}
+
+// newWebhookHandler return a handler which passes updates from Telegram to the channel.
+// Requests without the secret token are rejected
+func newWebhookHandler(bot *tgbotapi.BotAPI, secret string, updates chan<- tgbotapi.Update) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretHeader)), []byte(secret)) != 1 {
+			http.Error(w, "invalid secret token", http.StatusUnauthorized)
+			return
+		}
+
+		update, err := bot.HandleUpdate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case updates <- *update:
+		case <-r.Context().Done():
+			// Telegram sends the update again
+			http.Error(w, "timeout", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// randomSecret return a random secret token allowed by Telegram: 1-256 characters of A-Z, a-z, 0-9, _ and -
+func randomSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
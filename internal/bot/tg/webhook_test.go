@@ -0,0 +1,43 @@
+package tg
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	updates := make(chan tgbotapi.Update, 1)
+	handler := newWebhookHandler(&tgbotapi.BotAPI{}, "secret", updates)
+
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		status int
+	}{
+		{name: "no secret", body: `{"update_id": 1}`, status: http.StatusUnauthorized},
+		{name: "wrong secret", secret: "wrong", body: `{"update_id": 1}`, status: http.StatusUnauthorized},
+		{name: "invalid body", secret: "secret", body: `{`, status: http.StatusBadRequest},
+		{name: "update", secret: "secret", body: `{"update_id": 42}`, status: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			if tc.secret != "" {
+				request.Header.Set(webhookSecretHeader, tc.secret)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			assert.Equal(t, tc.status, recorder.Code)
+		})
+	}
+
+	update := <-updates
+	assert.Equal(t, 42, update.UpdateID)
+	assert.Empty(t, updates)
+}
@@ -0,0 +1,122 @@
+package youtube
+
+import (
+	"container/list"
+	"github.com/kkdai/youtube/v2"
+	"sync"
+	"time"
+)
+
+const (
+	videoCacheSize = 512
+	// videoCacheTTL is well below the lifetime of stream URLs (about 6 hours),
+	// so formats of a cached video can be downloaded
+	videoCacheTTL = 30 * time.Minute
+
+	playlistCacheSize = 128
+	// playlistCacheTTL is long enough to page and select entries of a keyboard without fetching them again
+	playlistCacheTTL = 10 * time.Minute
+)
+
+var (
+	// videos is a process-wide cache of videos' metadata by their ids shared by all downloaders
+	videos = newCache(videoCacheSize, videoCacheTTL, copyVideo)
+	// playlists is a process-wide cache of playlists by their links
+	playlists = newCache(playlistCacheSize, playlistCacheTTL, copyPlaylist)
+	// channelIDs caches ids of channels by their links, so their uploads are found without requests
+	channelIDs = newCache(playlistCacheSize, playlistCacheTTL, func(id string) string { return id })
+)
+
+// cache is an LRU cache of values by keys, its entries expire after ttl.
+// Values are copied by copyValue when they're put and got, so callers can change them
+type cache[V any] struct {
+	mu        sync.Mutex
+	size      int
+	ttl       time.Duration
+	copyValue func(V) V
+	order     *list.List // the most recently used entry is at the front
+	items     map[string]*list.Element
+}
+
+type cacheEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+func newCache[V any](size int, ttl time.Duration, copyValue func(V) V) *cache[V] {
+	return &cache[V]{
+		size:      size,
+		ttl:       ttl,
+		copyValue: copyValue,
+		order:     list.New(),
+		items:     make(map[string]*list.Element),
+	}
+}
+
+// Get return a copy of the cached value of the key
+func (c *cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := element.Value.(*cacheEntry[V])
+	if time.Now().After(entry.expires) {
+		c.removeElement(element)
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	return c.copyValue(entry.value), true
+}
+
+// Put caches a copy of the value by the key, evicting the least recently used one if the cache is full
+func (c *cache[V]) Put(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry[V]{key: key, value: c.copyValue(value), expires: time.Now().Add(c.ttl)}
+	if element, ok := c.items[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Remove drops the value of the key from the cache
+func (c *cache[V]) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *cache[V]) removeElement(element *list.Element) {
+	entry := c.order.Remove(element).(*cacheEntry[V])
+	delete(c.items, entry.key)
+}
+
+// copyVideo return a copy of the video with its own list of formats
+func copyVideo(video *youtube.Video) *youtube.Video {
+	copied := *video
+	copied.Formats = append(youtube.FormatList(nil), video.Formats...)
+	return &copied
+}
+
+// copyPlaylist return a copy of the playlist with its own list of entries, the entries themselves are shared
+func copyPlaylist(playlist *youtube.Playlist) *youtube.Playlist {
+	copied := *playlist
+	copied.Videos = append([]*youtube.PlaylistEntry(nil), playlist.Videos...)
+	return &copied
+}
@@ -0,0 +1,113 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kkdai/youtube/v2"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	channelIDPrefix   = "UC"
+	uploadsPrefix     = "UU"
+	playlistURLFormat = "https://www.youtube.com/playlist?list=%s"
+	channelPageSize   = 2 * 1024 * 1024 // enough to find the channel id in the page head
+)
+
+var (
+	channelIDPattern    = regexp.MustCompile(`^UC[\w-]{22}$`)
+	channelPagePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`<link rel="canonical" href="https://www\.youtube\.com/channel/(UC[\w-]{22})"`),
+		regexp.MustCompile(`"externalId":"(UC[\w-]{22})"`),
+		regexp.MustCompile(`"channelId":"(UC[\w-]{22})"`),
+	}
+
+	ErrChannelNotFound = errors.New("channel id not found")
+)
+
+// IsChannelURL return true if the link leads to a channel: /@handle, /channel/UC..., /c/name or /user/name
+func IsChannelURL(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	path := u.Path
+	return strings.HasPrefix(path, "/@") ||
+		strings.HasPrefix(path, "/channel/"+channelIDPrefix) ||
+		strings.HasPrefix(path, "/c/") ||
+		strings.HasPrefix(path, "/user/")
+}
+
+// UploadsPlaylistURL return a link on the playlist with all uploads of the channel
+func UploadsPlaylistURL(channelID string) string {
+	return fmt.Sprintf(playlistURLFormat, uploadsPrefix+strings.TrimPrefix(channelID, channelIDPrefix))
+}
+
+// ResolveChannelID return the UC... id of a channel by its link.
+// Links with a handle or a custom name are resolved by the channel page
+func (ytd *YouTubeDownloader) ResolveChannelID(ctx context.Context, channelURL string) (string, error) {
+	u, err := url.Parse(channelURL)
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) > 1 && parts[0] == "channel" && channelIDPattern.MatchString(parts[1]) {
+		return parts[1], nil
+	}
+
+	log.Printf("Resolving channel from URL: %s", channelURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, channelURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	// skip the cookie consent page which is shown instead of the channel in some regions
+	req.AddCookie(&http.Cookie{Name: "CONSENT", Value: "YES+"})
+
+	httpClient := ytd.Downloader.Client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", youtube.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, channelPageSize))
+	if err != nil {
+		return "", err
+	}
+
+	for _, pattern := range channelPagePatterns {
+		if match := pattern.FindSubmatch(page); match != nil {
+			return string(match[1]), nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrChannelNotFound, channelURL)
+}
+
+// GetChannelUploads return the playlist of the channel's uploads, the most recent first.
+// Ids of channels are cached, so cached uploads are got without requests
+func (ytd *YouTubeDownloader) GetChannelUploads(ctx context.Context, channelURL string) (*youtube.Playlist, error) {
+	channelID, ok := channelIDs.Get(channelURL)
+	if !ok {
+		var err error
+		if channelID, err = ytd.ResolveChannelID(ctx, channelURL); err != nil {
+			return nil, err
+		}
+		channelIDs.Put(channelURL, channelID)
+	}
+	return ytd.GetPlaylist(UploadsPlaylistURL(channelID))
+}
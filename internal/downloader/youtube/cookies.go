@@ -0,0 +1,127 @@
+package youtube
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/kkdai/youtube/v2"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// ErrAuthRequired is returned for age-restricted and members-only videos which can't be fetched without an account
+var ErrAuthRequired = errors.New("video requires authentication")
+
+var (
+	cookieJarMu sync.RWMutex
+	cookieJar   http.CookieJar
+)
+
+// LoadCookies reads a cookies file in the Netscape format, exported from a browser,
+// and returns a jar with its cookies
+func LoadCookies(path string) (http.CookieJar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open cookies file: %w", err)
+	}
+	defer file.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cookie, host, err := parseCookieLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("cookies file line %d: %w", lineNo, err)
+		}
+		cookie.HttpOnly = httpOnly
+
+		jar.SetCookies(&url.URL{Scheme: "https", Host: host, Path: "/"}, []*http.Cookie{cookie})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return jar, nil
+}
+
+// parseCookieLine parses "domain includeSubdomains path secure expiration name value" separated by tabs
+// and return the cookie with the host it's set for
+func parseCookieLine(line string) (*http.Cookie, string, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 7 {
+		return nil, "", fmt.Errorf("expected 7 fields, got %d", len(fields))
+	}
+
+	expiration, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid expiration: %w", err)
+	}
+
+	cookie := &http.Cookie{
+		Path:   fields[2],
+		Secure: strings.EqualFold(fields[3], "TRUE"),
+		Name:   fields[5],
+		Value:  fields[6],
+	}
+	if expiration > 0 {
+		cookie.Expires = time.Unix(expiration, 0)
+	}
+	// a host-only cookie has no domain attribute, the jar keeps it for the host only
+	if strings.EqualFold(fields[1], "TRUE") {
+		cookie.Domain = fields[0]
+	}
+	return cookie, strings.TrimPrefix(fields[0], "."), nil
+}
+
+// SetCookies sets the jar used by HTTP clients of all new downloaders, nil disables cookies
+func SetCookies(jar http.CookieJar) {
+	cookieJarMu.Lock()
+	defer cookieJarMu.Unlock()
+	cookieJar = jar
+}
+
+// currentCookieJar return the jar set by SetCookies
+func currentCookieJar() http.CookieJar {
+	cookieJarMu.RLock()
+	defer cookieJarMu.RUnlock()
+	return cookieJar
+}
+
+// CookiesConfigured return true if the downloaders send requests with an account's cookies
+func CookiesConfigured() bool {
+	return currentCookieJar() != nil
+}
+
+// isAuthRequired return true if YouTube refused to play the video without signing in
+func isAuthRequired(err error) bool {
+	if errors.Is(err, youtube.ErrLoginRequired) {
+		return true
+	}
+
+	var statusErr *youtube.ErrPlayabiltyStatus
+	if errors.As(err, &statusErr) {
+		reason := strings.ToLower(statusErr.Reason)
+		return statusErr.Status == "LOGIN_REQUIRED" ||
+			strings.Contains(reason, "sign in") ||
+			strings.Contains(reason, "members")
+	}
+	return false
+}
@@ -5,17 +5,29 @@ import (
 	"fmt"
 	"github.com/kkdai/youtube/v2"
 	"log"
+	"os"
 	"strings"
 )
 
-// DownloadVideoWithFormat download a video according to a format
+// DownloadVideoWithFormat download a video according to a format.
+// The download is started over if the stream was throttled or interrupted
 func (ytd *YouTubeDownloader) DownloadVideoWithFormat(
 	ctx context.Context,
 	video *youtube.Video,
 	format *youtube.Format,
 	outputFile string) error {
 
-	if err := ytd.Downloader.Download(ctx, video, format, outputFile); err != nil {
+	destFile, err := ytd.getOutputFile(video, format, outputFile)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(destFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := ytd.videoDLWorker(ctx, out, video, format); err != nil {
 		log.Printf("Error after Download : %s", err)
 		return err
 	}
@@ -23,7 +35,7 @@ func (ytd *YouTubeDownloader) DownloadVideoWithFormat(
 }
 
 // DownloadVideo downloads video with the lowest quality
-func (ytd *YouTubeDownloader) DownloadVideo(video *youtube.Video) (pathAndName string, err error) {
+func (ytd *YouTubeDownloader) DownloadVideo(ctx context.Context, video *youtube.Video) (pathAndName string, err error) {
 	title := SanitizeFilename(video.Title)
 	pathAndName = DOWNLOAD_DIR + title + FORMAT_MP4
 
@@ -35,7 +47,6 @@ func (ytd *YouTubeDownloader) DownloadVideo(video *youtube.Video) (pathAndName s
 	formats.Sort()
 	format := formats[len(formats)-1]
 
-	ctx := context.Background()
 	if err := ytd.DownloadVideoWithFormat(ctx, video, &format, ""); err != nil {
 		fmt.Println(err)
 	}
@@ -44,7 +55,7 @@ func (ytd *YouTubeDownloader) DownloadVideo(video *youtube.Video) (pathAndName s
 }
 
 // DownloadAudio downloads audio with the highest quality
-func (ytd *YouTubeDownloader) DownloadAudio(video *youtube.Video) (pathAndName string, err error) {
+func (ytd *YouTubeDownloader) DownloadAudio(ctx context.Context, video *youtube.Video) (pathAndName string, err error) {
 
 	formats := video.Formats.WithAudioChannels()
 	formats, err = WithFormats(&formats, AUDIO_PREFIX)
@@ -53,7 +64,7 @@ func (ytd *YouTubeDownloader) DownloadAudio(video *youtube.Video) (pathAndName s
 	}
 	formats.Sort()
 	format := formats[0]
-	if err := ytd.DownloadVideoWithFormat(context.Background(), video, &format, ""); err != nil {
+	if err := ytd.DownloadVideoWithFormat(ctx, video, &format, ""); err != nil {
 		fmt.Println(err)
 	}
 
@@ -65,9 +76,12 @@ func (ytd *YouTubeDownloader) DownloadAudio(video *youtube.Video) (pathAndName s
 }
 
 // DownloadWithFormat downloads a file by a link with a certain video format
-func (ytd *YouTubeDownloader) DownloadWithFormat(video *youtube.Video, format youtube.Format) (pathAndName string, err error) {
+func (ytd *YouTubeDownloader) DownloadWithFormat(ctx context.Context, video *youtube.Video, format youtube.Format) (pathAndName string, err error) {
+	if IsLive(video) {
+		return "", ErrLiveNotSupported
+	}
 	if !isAcceptableFileSize(format) {
-		return "", fmt.Errorf("file's size too large. Acceptable size is %.2f Mb", MaxFileSize/(1024*1024))
+		return "", fmt.Errorf("%w: acceptable size is %.2f Mb", ErrTooLarge, MaxFileSize/(1024*1024))
 	}
 
 	title := SanitizeFilename(video.Title)
@@ -76,7 +90,7 @@ func (ytd *YouTubeDownloader) DownloadWithFormat(video *youtube.Video, format yo
 	mimeType = mimeTypeParts[0]
 	pathAndName = DOWNLOAD_DIR + title + canonicals[mimeType]
 
-	err = ytd.DownloadVideoWithFormat(context.Background(), video, &format, "")
+	err = ytd.DownloadVideoWithFormat(ctx, video, &format, "")
 	if err != nil {
 		log.Println(err)
 		return pathAndName, err
@@ -90,7 +104,7 @@ func (ytd *YouTubeDownloader) DownloadWithFormat(video *youtube.Video, format yo
 // DownloadWithFormatComposite downloads a file by a link with a certain video format and returns a path to file
 func (ytd *YouTubeDownloader) DownloadWithFormatComposite(videoURL string, format youtube.Format) (pathAndName string, err error) {
 	if !isAcceptableFileSize(format) {
-		return "", fmt.Errorf("file's size too large. Acceptable size is %.2f Mb", MaxFileSize/(1024*1024))
+		return "", fmt.Errorf("%w: acceptable size is %.2f Mb", ErrTooLarge, MaxFileSize/(1024*1024))
 	}
 
 	video, err := ytd.GetVideo(videoURL)
@@ -98,6 +112,9 @@ func (ytd *YouTubeDownloader) DownloadWithFormatComposite(videoURL string, forma
 		log.Print(err)
 		return "", err
 	}
+	if IsLive(video) {
+		return "", ErrLiveNotSupported
+	}
 
 	ctx := context.Background()
 	pathAndName, err = ytd.DownloadVideoWithFormatComposite(ctx, "", video, format.QualityLabel, format.MimeType, "")
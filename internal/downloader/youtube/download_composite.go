@@ -9,6 +9,7 @@ import (
 	"github.com/vbauerster/mpb/v5"
 	"github.com/vbauerster/mpb/v5/decor"
 	"io"
+	"log"
 	"mime"
 	"os"
 	"os/exec"
@@ -29,6 +30,7 @@ var canonicals = map[string]string{
 	"video/ogg":        ".ogv",
 	"video/mp2t":       ".ts",
 	"audio/mp4":        ".m4a",
+	"audio/webm":       ".weba",
 }
 
 const defaultExtension = ".mov"
@@ -37,6 +39,7 @@ type progress struct {
 	contentLength     float64
 	totalWrittenBytes float64
 	downloadLevel     float64
+	onLevel           func(level float64) // called when downloadLevel grows
 }
 
 func (dl *progress) Write(p []byte) (n int, err error) {
@@ -45,6 +48,9 @@ func (dl *progress) Write(p []byte) (n int, err error) {
 	currentPercent := (dl.totalWrittenBytes / dl.contentLength) * 100
 	if (dl.downloadLevel <= currentPercent) && (dl.downloadLevel < 100) {
 		dl.downloadLevel++
+		if dl.onLevel != nil {
+			dl.onLevel(dl.downloadLevel)
+		}
 	}
 	return
 }
@@ -92,20 +98,28 @@ func (ytd *YouTubeDownloader) DownloadVideoWithFormatComposite(ctx context.Conte
 		os.Remove(audioFile.Name())
 	}()
 
+	// the progress of both files is reported as a single download
+	videoShare := 50.0
+	if total := videoFormat.ContentLength + audioFormat.ContentLength; total > 0 {
+		videoShare = float64(videoFormat.ContentLength) * 100 / float64(total)
+	}
+
 	log.Debug("Downloading video file...")
-	err = ytd.videoDLWorker(ctx, videoFile, v, videoFormat)
+	err = ytd.videoDLWorker(withProgressRange(ctx, 0, videoShare), videoFile, v, videoFormat)
 	if err != nil {
 		return "", err
 	}
 
 	log.Debug("Downloading audio file...")
-	err = ytd.videoDLWorker(ctx, audioFile, v, audioFormat)
+	err = ytd.videoDLWorker(withProgressRange(ctx, videoShare, 100), audioFile, v, audioFormat)
 	if err != nil {
 		return "", err
 	}
 
+	reportProgress(ctx, StageMerging, 100)
+
 	//nolint:gosec
-	ffmpegVersionCmd := exec.Command("ffmpeg", "-y",
+	ffmpegVersionCmd := exec.CommandContext(ctx, "ffmpeg", "-y",
 		"-i", videoFile.Name(),
 		"-i", audioFile.Name(),
 		"-c", "copy", // Just copy without re-encoding
@@ -117,17 +131,44 @@ func (ytd *YouTubeDownloader) DownloadVideoWithFormatComposite(ctx context.Conte
 	ffmpegVersionCmd.Stdout = os.Stdout
 	log.Info("merging video and audio", "output", destFile)
 
-	return destFile, ffmpegVersionCmd.Run()
+	return destFile, ffmpegError("merging video and audio", ffmpegVersionCmd.Run())
 }
 
+// videoDLWorker downloads the format into out.
+// The stream is requested again and out is rewritten if the download was throttled or interrupted
 func (ytd *YouTubeDownloader) videoDLWorker(ctx context.Context, out *os.File, video *youtube.Video, format *youtube.Format) error {
+	return ytd.withRetry(ctx, "GetStreamContext", func() error {
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if err := out.Truncate(0); err != nil {
+			return err
+		}
+		err := ytd.copyStream(ctx, out, video, format)
+		if isThrottled(err) {
+			// the stream URL of a cached video may be expired
+			if refreshErr := ytd.refreshFormat(video, format); refreshErr != nil {
+				log.Printf("can't refresh video %s: %s", video.ID, refreshErr)
+			}
+		}
+		return err
+	})
+}
+
+// copyStream copies the stream of the format into out showing a progress bar
+// and reporting the progress to the ProgressFunc of ctx
+func (ytd *YouTubeDownloader) copyStream(ctx context.Context, out *os.File, video *youtube.Video, format *youtube.Format) error {
 	stream, size, err := ytd.Downloader.GetStreamContext(ctx, video, format)
 	if err != nil {
 		return err
 	}
+	defer stream.Close()
 
 	prog := &progress{
 		contentLength: float64(size),
+		onLevel: func(level float64) {
+			reportProgress(ctx, StageDownloading, level)
+		},
 	}
 
 	// create progress bar
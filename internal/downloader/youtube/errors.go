@@ -0,0 +1,74 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kkdai/youtube/v2"
+	"strings"
+)
+
+// Errors of the downloader which the user can act on, they wrap errors of YouTube and ffmpeg,
+// so callers tell them apart with errors.Is. ErrAuthRequired and ErrNotLive are among them
+var (
+	ErrInvalidLink      = errors.New("invalid link")
+	ErrPrivate          = errors.New("video is private")
+	ErrGeoBlocked       = errors.New("video isn't available in the country")
+	ErrLiveNotSupported = errors.New("live streams can only be recorded")
+	ErrTooLarge         = errors.New("file is larger than MaxFileSize")
+	ErrThrottled        = errors.New("YouTube throttles requests")
+	ErrFFmpeg           = errors.New("ffmpeg failed")
+)
+
+// classified are errors which classify doesn't wrap again
+var classified = []error{ErrInvalidLink, ErrPrivate, ErrGeoBlocked, ErrLiveNotSupported, ErrTooLarge, ErrThrottled,
+	ErrFFmpeg, ErrAuthRequired, ErrNotLive}
+
+// classify wraps an error of YouTube with the error of the downloader it belongs to, other errors are returned as is
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, target := range classified {
+		if errors.Is(err, target) {
+			return err
+		}
+	}
+
+	switch {
+	case errors.Is(err, youtube.ErrInvalidCharactersInVideoID), errors.Is(err, youtube.ErrVideoIDMinLength),
+		errors.Is(err, youtube.ErrInvalidPlaylist):
+		return fmt.Errorf("%w: %w", ErrInvalidLink, err)
+	case isPrivate(err):
+		return fmt.Errorf("%w: %w", ErrPrivate, err)
+	case isAuthRequired(err):
+		return wrapAuthError(err)
+	case isGeoBlocked(err):
+		return fmt.Errorf("%w: %w", ErrGeoBlocked, err)
+	case isThrottled(err):
+		return fmt.Errorf("%w: %w", ErrThrottled, err)
+	}
+	return err
+}
+
+// isPrivate return true if the owner restricted access to the video
+func isPrivate(err error) bool {
+	if errors.Is(err, youtube.ErrVideoPrivate) {
+		return true
+	}
+	var statusErr *youtube.ErrPlayabiltyStatus
+	return errors.As(err, &statusErr) && strings.Contains(strings.ToLower(statusErr.Reason), "private")
+}
+
+// isGeoBlocked return true if the video isn't available in the country of the bot or its proxy
+func isGeoBlocked(err error) bool {
+	var statusErr *youtube.ErrPlayabiltyStatus
+	return errors.As(err, &statusErr) && strings.Contains(strings.ToLower(statusErr.Reason), "country")
+}
+
+// ffmpegError wraps an error of ffmpeg with ErrFFmpeg
+func ffmpegError(operation string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %s: %w", ErrFFmpeg, operation, err)
+}
@@ -0,0 +1,126 @@
+package youtube
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/kkdai/youtube/v2"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxRecordDuration limits recording of a stream "until it ends"
+	MaxRecordDuration = 4 * time.Hour
+
+	liveFileSuffix = " (live)"
+)
+
+var ErrNotLive = errors.New("video isn't a live stream")
+
+// RecordProgress describes the state of a running recording
+type RecordProgress struct {
+	Elapsed time.Duration
+	Size    int64 // in bites
+}
+
+// IsLive return true if the video is an ongoing live stream
+func IsLive(video *youtube.Video) bool {
+	return video.HLSManifestURL != ""
+}
+
+// RecordLiveStream captures the HLS stream of the live video with ffmpeg for the duration
+// or until the stream ends if duration is 0. The recording is limited by MaxFileSize.
+// onProgress is called with the current state every time ffmpeg reports it and may be nil
+func (ytd *YouTubeDownloader) RecordLiveStream(ctx context.Context, video *youtube.Video, duration time.Duration,
+	onProgress func(RecordProgress)) (string, error) {
+
+	if !IsLive(video) {
+		return "", ErrNotLive
+	}
+	if duration <= 0 || duration > MaxRecordDuration {
+		duration = MaxRecordDuration
+	}
+
+	if err := os.MkdirAll(ytd.Downloader.OutputDir, 0o755); err != nil {
+		return "", err
+	}
+	pathAndName := filepath.Join(ytd.Downloader.OutputDir, SanitizeFilename(video.Title)+liveFileSuffix+FORMAT_MP4)
+
+	log.Printf("Recording live stream %s for %s", video.ID, duration)
+
+	args := []string{"-y"}
+	if ytd.proxy != nil && strings.HasPrefix(ytd.proxy.URL.Scheme, "http") {
+		// ffmpeg supports only HTTP proxies
+		args = append(args, "-http_proxy", ytd.proxy.URL.String())
+	}
+
+	//nolint:gosec
+	ffmpegCmd := exec.CommandContext(ctx, "ffmpeg", append(args,
+		"-i", video.HLSManifestURL,
+		"-t", strconv.Itoa(int(duration.Seconds())),
+		"-fs", strconv.FormatInt(int64(MaxFileSize), 10), // stop before the file gets too large to send
+		"-c", "copy", // Just copy without re-encoding
+		"-bsf:a", "aac_adtstoasc",
+		"-progress", "pipe:1",
+		"-nostats",
+		pathAndName,
+		"-loglevel", "warning",
+	)...)
+	ffmpegCmd.Stderr = os.Stderr
+
+	stdout, err := ffmpegCmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := ffmpegCmd.Start(); err != nil {
+		return "", ffmpegError("recording live stream", err)
+	}
+
+	readRecordProgress(stdout, onProgress)
+
+	if err := ffmpegCmd.Wait(); err != nil {
+		if fileExists(pathAndName) {
+			// the stream has been interrupted, but the recorded part is still valid
+			log.Printf("Recording of %s stopped: %s", video.ID, err)
+			return pathAndName, nil
+		}
+		return "", ffmpegError("recording live stream", err)
+	}
+
+	log.Printf("RecordLiveStream return path: %s", pathAndName)
+	return pathAndName, nil
+}
+
+// readRecordProgress parses "key=value" blocks of ffmpeg -progress output
+func readRecordProgress(output io.Reader, onProgress func(RecordProgress)) {
+	var progress RecordProgress
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "out_time_us":
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+				progress.Elapsed = time.Duration(us) * time.Microsecond
+			}
+		case "total_size":
+			if size, err := strconv.ParseInt(value, 10, 64); err == nil {
+				progress.Size = size
+			}
+		case "progress":
+			if onProgress != nil {
+				onProgress(progress)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kkdai/youtube/v2"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	albumTitlePrefix   = "Album - "
+	artistTopicSuffix  = " - Topic"
+	musicAudioMimeType = "audio/mp4"
+)
+
+// AudioTags describes the metadata written into a music track
+type AudioTags struct {
+	Title      string
+	Artist     string
+	Album      string
+	Track      int
+	TrackTotal int
+	CoverPath  string // path to the artwork, may be empty
+}
+
+// AlbumTitle returns a playlist title without the "Album - " prefix YouTube Music adds to albums
+func AlbumTitle(playlist *youtube.Playlist) string {
+	return strings.TrimPrefix(playlist.Title, albumTitlePrefix)
+}
+
+// ArtistName returns an author of the playlist entry without the " - Topic" suffix of auto-generated channels
+func ArtistName(author string) string {
+	return strings.TrimSuffix(author, artistTopicSuffix)
+}
+
+// AlbumCoverURL returns the largest thumbnail of the first playlist entry, which YouTube Music uses as the album artwork
+func AlbumCoverURL(playlist *youtube.Playlist) string {
+	if len(playlist.Videos) == 0 {
+		return ""
+	}
+
+	var cover youtube.Thumbnail
+	for _, thumbnail := range playlist.Videos[0].Thumbnails {
+		if thumbnail.Width*thumbnail.Height > cover.Width*cover.Height {
+			cover = thumbnail
+		}
+	}
+	return cover.URL
+}
+
+// DownloadCover downloads the artwork by its URL and returns a path to the file
+func (ytd *YouTubeDownloader) DownloadCover(ctx context.Context, coverURL string) (string, error) {
+	if coverURL == "" {
+		return "", errors.New("empty cover url")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, coverURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	httpClient := ytd.Downloader.Client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", youtube.ErrUnexpectedStatusCode(resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(ytd.Downloader.OutputDir, 0o755); err != nil {
+		return "", err
+	}
+	coverFile, err := os.CreateTemp(ytd.Downloader.OutputDir, "cover_*.img")
+	if err != nil {
+		return "", err
+	}
+	defer coverFile.Close()
+
+	if _, err := io.Copy(coverFile, resp.Body); err != nil {
+		os.Remove(coverFile.Name())
+		return "", err
+	}
+	return coverFile.Name(), nil
+}
+
+// DownloadTaggedAudio downloads the best audio/mp4 format of the video,
+// writes tags and the cover into it and returns a path to the track named by its number
+func (ytd *YouTubeDownloader) DownloadTaggedAudio(ctx context.Context, video *youtube.Video, tags AudioTags) (string, error) {
+	formats := video.Formats.Type(musicAudioMimeType)
+	if len(formats) == 0 {
+		return "", fmt.Errorf("no %s format for %s", musicAudioMimeType, video.ID)
+	}
+	formats.Sort()
+	format := formats[0]
+
+	if err := os.MkdirAll(ytd.Downloader.OutputDir, 0o755); err != nil {
+		return "", err
+	}
+	rawFile, err := os.CreateTemp(ytd.Downloader.OutputDir, "track_*"+FORMAT_M4A)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		rawFile.Close()
+		os.Remove(rawFile.Name())
+	}()
+
+	if err := ytd.videoDLWorker(ctx, rawFile, video, &format); err != nil {
+		return "", err
+	}
+
+	fileName := SanitizeFilename(tags.Title) + FORMAT_M4A
+	if tags.Track > 0 {
+		fileName = fmt.Sprintf("%02d - %s", tags.Track, fileName)
+	}
+	pathAndName := filepath.Join(ytd.Downloader.OutputDir, fileName)
+
+	if err := TagAudio(ctx, rawFile.Name(), pathAndName, tags); err != nil {
+		return "", err
+	}
+
+	log.Printf("DownloadTaggedAudio return path: %s", pathAndName)
+	return pathAndName, nil
+}
+
+// TagAudio copies the audio stream from src to dst, writing tags and attaching the cover as artwork
+func TagAudio(ctx context.Context, src, dst string, tags AudioTags) error {
+	args := []string{"-y", "-i", src}
+	if tags.CoverPath != "" {
+		args = append(args, "-i", tags.CoverPath, "-map", "0:a", "-map", "1:v",
+			"-c:a", "copy", "-c:v", "mjpeg", "-disposition:v:0", "attached_pic")
+	} else {
+		args = append(args, "-map", "0:a", "-c:a", "copy")
+	}
+
+	metadata := map[string]string{
+		"title":        tags.Title,
+		"artist":       tags.Artist,
+		"album_artist": tags.Artist,
+		"album":        tags.Album,
+	}
+	if tags.Track > 0 {
+		track := strconv.Itoa(tags.Track)
+		if tags.TrackTotal > 0 {
+			track += "/" + strconv.Itoa(tags.TrackTotal)
+		}
+		metadata["track"] = track
+	}
+	for key, value := range metadata {
+		if value != "" {
+			args = append(args, "-metadata", key+"="+value)
+		}
+	}
+	args = append(args, dst, "-loglevel", "warning")
+
+	//nolint:gosec
+	ffmpegCmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	ffmpegCmd.Stderr = os.Stderr
+	ffmpegCmd.Stdout = os.Stdout
+	return ffmpegError("tagging audio", ffmpegCmd.Run())
+}
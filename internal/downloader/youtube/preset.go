@@ -0,0 +1,128 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kkdai/youtube/v2"
+	"strconv"
+)
+
+// Preset is a predefined choice of format used where the user can't pick from the whole format list
+type Preset string
+
+const (
+	PresetBestAudio Preset = "audio"
+	Preset720p      Preset = "720p"
+	PresetBestFit   Preset = "fit"
+
+	presetVideoMimeType = "video/mp4"
+	presetAudioMimeType = "audio/mp4"
+	presetQualityHeight = 720
+)
+
+// FormatPreference describes a format selected automatically
+type FormatPreference struct {
+	Audio         bool   // an audio format instead of a video one
+	AudioMimeType string // the mime type of an audio format, audio/mp4 if it's empty
+	MaxHeight     int    // the max height of a video format, 0 means any
+	Fit           bool   // a video with the best audio must be smaller than MaxFileSize
+}
+
+// Presets are all supported presets in the order they are offered to the user
+var Presets = []Preset{PresetBestAudio, Preset720p, PresetBestFit}
+
+var ErrNoPresetFormat = errors.New("no format matches the preset")
+
+// IsAudio return true if the preset produces an audio file
+func (p Preset) IsAudio() bool {
+	return p == PresetBestAudio
+}
+
+// ParsePreset return a preset by its name
+func ParsePreset(name string) (Preset, error) {
+	for _, preset := range Presets {
+		if string(preset) == name {
+			return preset, nil
+		}
+	}
+	return "", fmt.Errorf("unknown preset: %s", name)
+}
+
+// preference return the format preference of the preset
+func (p Preset) preference() FormatPreference {
+	switch p {
+	case PresetBestAudio:
+		return FormatPreference{Audio: true}
+	case Preset720p:
+		return FormatPreference{MaxHeight: presetQualityHeight}
+	default:
+		return FormatPreference{Fit: true}
+	}
+}
+
+// SelectPresetFormat returns the format of the video matching the preset.
+// For video presets it is a video-only format, an audio track is merged to it while downloading
+func SelectPresetFormat(video *youtube.Video, preset Preset) (youtube.Format, error) {
+	format, err := SelectFormat(video, preset.preference())
+	if err != nil {
+		return youtube.Format{}, fmt.Errorf("%w: %s for %s", ErrNoPresetFormat, preset, video.ID)
+	}
+	return format, nil
+}
+
+// SelectFormat returns the best format of the video matching the preference.
+// A video format is a video-only one, an audio track is merged to it while downloading
+func SelectFormat(video *youtube.Video, preference FormatPreference) (youtube.Format, error) {
+	if IsLive(video) {
+		return youtube.Format{}, ErrLiveNotSupported
+	}
+	audioMimeType := preference.AudioMimeType
+	if audioMimeType == "" {
+		audioMimeType = presetAudioMimeType
+	}
+	audioFormats := video.Formats.Type(audioMimeType)
+	audioFormats.Sort()
+	if len(audioFormats) == 0 {
+		return youtube.Format{}, fmt.Errorf("%w: no %s for %s", ErrNoPresetFormat, audioMimeType, video.ID)
+	}
+	if preference.Audio {
+		return audioFormats[0], nil
+	}
+
+	audioSize, _ := getFileSize(audioFormats[0])
+	videoFormats := video.Formats.Type(presetVideoMimeType).AudioChannels(0)
+	videoFormats.Sort()
+
+	// formats are sorted from the best quality, so the first suitable one is taken
+	for _, format := range videoFormats {
+		if height := formatHeight(format); preference.MaxHeight > 0 && (height == 0 || height > preference.MaxHeight) {
+			continue
+		}
+		if preference.Fit {
+			size, err := getFileSize(format)
+			if err != nil || size+audioSize >= MaxFileSize {
+				continue
+			}
+		}
+		return format, nil
+	}
+	return youtube.Format{}, fmt.Errorf("%w: no video for %s", ErrNoPresetFormat, video.ID)
+}
+
+// formatHeight return the height of the video format by its size or quality label, 0 if it's unknown
+func formatHeight(format youtube.Format) int {
+	if format.Height > 0 {
+		return format.Height
+	}
+	height, _ := qualityHeight(format.QualityLabel)
+	return height
+}
+
+// qualityHeight return the height of a quality label, i.e. 720 for "720p60"
+func qualityHeight(qualityLabel string) (int, error) {
+	digits := 0
+	for digits < len(qualityLabel) && qualityLabel[digits] >= '0' && qualityLabel[digits] <= '9' {
+		digits++
+	}
+	return strconv.Atoi(qualityLabel[:digits])
+}
@@ -0,0 +1,36 @@
+package youtube
+
+import "context"
+
+// Stage is a stage of a download reported to a ProgressFunc
+type Stage int
+
+const (
+	StageDownloading Stage = iota
+	StageMerging           // video and audio of a composite download are merged by ffmpeg
+)
+
+// ProgressFunc receives the stage of a download and percents of the whole download done
+type ProgressFunc func(stage Stage, percent float64)
+
+type progressKey struct{}
+
+// WithProgress return a context which reports the progress of downloads made with it to f
+func WithProgress(ctx context.Context, f ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+// reportProgress reports the progress to the ProgressFunc of ctx if there is one
+func reportProgress(ctx context.Context, stage Stage, percent float64) {
+	if f, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		f(stage, percent)
+	}
+}
+
+// withProgressRange return a context which reports the progress of a part of the download
+// as the range [from, to] of the whole download's percents
+func withProgressRange(ctx context.Context, from, to float64) context.Context {
+	return WithProgress(ctx, func(stage Stage, percent float64) {
+		reportProgress(ctx, stage, from+percent*(to-from)/100)
+	})
+}
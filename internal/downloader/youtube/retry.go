@@ -0,0 +1,129 @@
+package youtube
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"github.com/kkdai/youtube/v2"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	retryAttempts  = 4 // attempts after the first failed request
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// Retry metrics are published by expvar, keyed by the operation name
+var (
+	retryCounter    = expvar.NewMap("youtube_retries")
+	throttleCounter = expvar.NewMap("youtube_throttled")
+	failureCounter  = expvar.NewMap("youtube_failures")
+)
+
+// withRetry calls fn with retry and reports the result of every attempt to the proxy pool.
+// The last error is classified, so the user can be told why the request failed
+func (ytd *YouTubeDownloader) withRetry(ctx context.Context, operation string, fn func() error) error {
+	return classify(retry(ctx, operation, func() error {
+		err := fn()
+		ytd.reportProxy(err)
+		return err
+	}))
+}
+
+// reportProxy counts requests failed because of the downloader's proxy and resets them on success
+func (ytd *YouTubeDownloader) reportProxy(err error) {
+	if ytd.proxy == nil {
+		return
+	}
+	var netErr net.Error
+	switch {
+	case err == nil:
+		ytd.proxyPool.ReportSuccess(ytd.proxy)
+	case isThrottled(err), errors.As(err, &netErr):
+		ytd.proxyPool.ReportFailure(ytd.proxy)
+	}
+}
+
+// retry calls fn until it succeeds, returns a permanent error or the attempts run out.
+// Delays between attempts grow exponentially with a random jitter
+func retry(ctx context.Context, operation string, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		if isThrottled(err) {
+			throttleCounter.Add(operation, 1)
+		}
+		if !isRetryable(err) || attempt >= retryAttempts {
+			failureCounter.Add(operation, 1)
+			return err
+		}
+
+		delay := backoffDelay(attempt)
+		retryCounter.Add(operation, 1)
+		log.Printf("%s failed (attempt %d/%d), retrying in %s: %v", operation, attempt+1, retryAttempts+1, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoffDelay return the delay before the next attempt: an exponential delay with a jitter between its half and itself
+func backoffDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) //nolint:gosec
+}
+
+// isThrottled return true if YouTube refused the request because of too many requests
+func isThrottled(err error) bool {
+	var statusErr youtube.ErrUnexpectedStatusCode
+	if errors.As(err, &statusErr) {
+		return int(statusErr) == http.StatusTooManyRequests || int(statusErr) == http.StatusForbidden
+	}
+	return false
+}
+
+// isRetryable return true if the request may succeed being repeated:
+// throttling, server errors, signature errors and network failures
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr youtube.ErrUnexpectedStatusCode
+	if errors.As(err, &statusErr) {
+		return isThrottled(err) || int(statusErr) >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, youtube.ErrCipherNotFound) ||
+		errors.Is(err, youtube.ErrSignatureTimestampNotFound) ||
+		errors.Is(err, youtube.ErrReadOnClosedResBody) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// errors of signature deciphering aren't exported by the library
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "decipher") || strings.Contains(message, "signature")
+}
@@ -1,6 +1,7 @@
 package youtube
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	. "github.com/kkdai/youtube/v2"
@@ -20,6 +21,7 @@ const (
 
 	FORMAT_MP4 = ".mp4"
 	FORMAT_MP3 = ".mp3"
+	FORMAT_M4A = ".m4a"
 
 	MaxFileSize = 2147483648.0 // in bites (2 Gb)
 
@@ -32,6 +34,8 @@ var SupportedPrefixesFormat = []string{
 
 type YouTubeDownloader struct {
 	Downloader downloader.Downloader
+	proxy      *Proxy // proxy of the downloader's HTTP client, nil for direct connections
+	proxyPool  *ProxyPool
 }
 
 // SetDownloadDir sets dir to download
@@ -39,32 +43,121 @@ func (ytd *YouTubeDownloader) SetDownloadDir(dir string) {
 	ytd.Downloader.OutputDir = dir
 }
 
+// NewYouTubeDownloader return a downloader which HTTP client goes through the next proxy of the pool set by SetProxyPool
 func NewYouTubeDownloader() *YouTubeDownloader {
+	pool := currentProxyPool()
+	proxy := pool.Next()
 	return &YouTubeDownloader{
 		Downloader: downloader.Downloader{
-			Client:    Client{},
+			Client:    Client{HTTPClient: newHTTPClient(proxy)},
 			OutputDir: DOWNLOAD_DIR,
 		},
+		proxy:     proxy,
+		proxyPool: pool,
 	}
 }
 
 // GetVideo retrieves a YouTube video by its URL and returns a pointer to a
-// YouTube.Video struct that contains the video's metadata
+// YouTube.Video struct that contains the video's metadata.
+// Videos are taken from the process-wide cache and fetched by VideoInfo on a miss.
+// Stream URLs of cached formats are refreshed by downloads when they expire.
+// ErrInvalidLink is returned if the url has no id of a video
 func (ytd *YouTubeDownloader) GetVideo(url string) (*Video, error) {
+	id, err := ExtractVideoID(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidLink, err)
+	}
+	if video, ok := videos.Get(id); ok {
+		return video, nil
+	}
+
+	video, err := ytd.VideoInfo(url)
+	if err != nil {
+		return video, err
+	}
+	videos.Put(video.ID, video)
+	return video, nil
+}
+
+// FreshVideo fetches the video bypassing the cache and caches it.
+// It's used where the live status matters, since a cached video may be a stream which has already ended or started
+func (ytd *YouTubeDownloader) FreshVideo(url string) (*Video, error) {
+	video, err := ytd.VideoInfo(url)
+	if err != nil {
+		return video, err
+	}
+	videos.Put(video.ID, video)
+	return video, nil
+}
+
+// VideoInfo fetches the video's metadata from YouTube bypassing the cache.
+// Throttled and failed requests are retried with backoff.
+// ErrAuthRequired is returned if the video can't be fetched without cookies of an account,
+// ErrInvalidLink, ErrPrivate, ErrGeoBlocked and ErrThrottled if it can't be fetched at all
+func (ytd *YouTubeDownloader) VideoInfo(url string) (*Video, error) {
 	log.Printf("Getting video from URL: %s", url)
-	return ytd.Downloader.Client.GetVideo(url)
+	var video *Video
+	err := ytd.withRetry(context.Background(), "GetVideo", func() (err error) {
+		video, err = ytd.Downloader.Client.GetVideo(url)
+		return err
+	})
+	return video, err
+}
+
+// refreshFormat fetches the video again to replace expired stream URLs of its formats and updates the cache
+func (ytd *YouTubeDownloader) refreshFormat(video *Video, format *Format) error {
+	log.Printf("Refreshing stream URLs of video: %s", video.ID)
+	videos.Remove(video.ID)
+
+	fresh, err := ytd.VideoInfo(video.ID)
+	if err != nil {
+		return err
+	}
+	videos.Put(fresh.ID, fresh)
+
+	video.Formats = fresh.Formats
+	if freshFormats := fresh.Formats.Itag(format.ItagNo); len(freshFormats) > 0 {
+		*format = freshFormats[0]
+	}
+	return nil
 }
 
-// GetPlaylist playlist return Playlist struct
+// GetPlaylist playlist return Playlist struct.
+// Playlists are taken from the process-wide cache, so pages of keyboards don't fetch them again.
+// Throttled and failed requests are retried with backoff
 func (ytd *YouTubeDownloader) GetPlaylist(url string) (*Playlist, error) {
+	if playlist, ok := playlists.Get(url); ok {
+		return playlist, nil
+	}
+
 	log.Printf("Getting playlist from URL: %s", url)
-	return ytd.Downloader.Client.GetPlaylist(url)
+	var playlist *Playlist
+	err := ytd.withRetry(context.Background(), "GetPlaylist", func() (err error) {
+		playlist, err = ytd.Downloader.Client.GetPlaylist(url)
+		return err
+	})
+	if err != nil {
+		return playlist, err
+	}
+	playlists.Put(url, playlist)
+	return playlist, nil
 }
 
-// GetVideoFromPlaylistEntry return certain Video from playlist
+// GetVideoFromPlaylistEntry return certain Video from playlist through the cache of videos
 func (ytd *YouTubeDownloader) GetVideoFromPlaylistEntry(entry *PlaylistEntry) (*Video, error) {
 	log.Printf("Getting video from playlist: %s", entry.Title)
-	return ytd.Downloader.Client.VideoFromPlaylistEntry(entry)
+	return ytd.GetVideo(entry.ID)
+}
+
+// wrapAuthError wraps the error with ErrAuthRequired if the video needs authentication
+func wrapAuthError(err error) error {
+	if err == nil || !isAuthRequired(err) {
+		return err
+	}
+	if CookiesConfigured() {
+		return fmt.Errorf("%w even with cookies: %w", ErrAuthRequired, err)
+	}
+	return fmt.Errorf("%w, no cookies are configured: %w", ErrAuthRequired, err)
 }
 
 // WithFormats returns a new FormatList that contains only a formats
@@ -87,8 +180,7 @@ func WithFormats(list *FormatList, prefix string) (FormatList, error) {
 
 // FormatWithAudioChannels return a new FormatList that contains only a formats with audio
 func FormatWithAudioChannels(videoURL string) (FormatList, error) {
-	client := Client{}
-	video, err := client.GetVideo(videoURL)
+	video, err := NewYouTubeDownloader().GetVideo(videoURL)
 	if err != nil {
 		log.Print(err)
 		return nil, err
@@ -101,13 +193,17 @@ func FormatWithAudioChannels(videoURL string) (FormatList, error) {
 // FormatWithAudioChannelsComposite return a new FormatList
 // that contains only a formats with audio in various quality combinations
 func FormatWithAudioChannelsComposite(videoURL string) (FormatList, error) {
-	client := Client{}
-	video, err := client.GetVideo(videoURL)
+	video, err := NewYouTubeDownloader().GetVideo(videoURL)
 	if err != nil {
 		log.Print(err)
 		return nil, err
 	}
 
+	return UniqueFormats(video), nil
+}
+
+// UniqueFormats return the video's formats without duplicated ItagNo
+func UniqueFormats(video *Video) FormatList {
 	var formats FormatList
 	uniqueFormats := make(map[int]bool)
 	for _, format := range video.Formats {
@@ -116,8 +212,7 @@ func FormatWithAudioChannelsComposite(videoURL string) (FormatList, error) {
 			uniqueFormats[format.ItagNo] = true
 		}
 	}
-
-	return formats, nil
+	return formats
 }
 
 // contains return true if item in slice
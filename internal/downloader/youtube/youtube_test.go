@@ -1,11 +1,18 @@
 package youtube
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/kkdai/youtube/v2"
 	"github.com/kkdai/youtube/v2/downloader"
 	"github.com/stretchr/testify/assert"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 type TestCase struct {
@@ -105,3 +112,179 @@ func TestGetVideo(t *testing.T) {
 		})
 	}
 }
+
+func TestIsChannelURL(t *testing.T) {
+	channelURLs := map[string]bool{
+		"https://www.youtube.com/@GoogleDevelopers":                 true,
+		"https://www.youtube.com/@GoogleDevelopers/videos":          true,
+		"https://www.youtube.com/channel/UC_x5XG1OV2P6uZZ5FSM9Ttw":  true,
+		"https://www.youtube.com/c/GoogleDevelopers":                true,
+		"https://www.youtube.com/watch?v=wPdX66-Ag2s":               false,
+		"https://youtube.com/playlist?list=PLGWn6fd74osw8DeWrcvgVo": false,
+	}
+	for link, expected := range channelURLs {
+		assert.Equal(t, expected, IsChannelURL(link), link)
+	}
+
+	assert.Equal(t, "https://www.youtube.com/playlist?list=UU_x5XG1OV2P6uZZ5FSM9Ttw",
+		UploadsPlaylistURL("UC_x5XG1OV2P6uZZ5FSM9Ttw"))
+}
+
+func TestIsRetryable(t *testing.T) {
+	retryable := map[error]bool{
+		youtube.ErrUnexpectedStatusCode(429): true,
+		youtube.ErrUnexpectedStatusCode(403): true,
+		youtube.ErrUnexpectedStatusCode(503): true,
+		youtube.ErrUnexpectedStatusCode(404): false,
+		youtube.ErrCipherNotFound:            true,
+		youtube.ErrVideoPrivate:              false,
+		youtube.ErrLoginRequired:             false,
+	}
+	for err, expected := range retryable {
+		assert.Equal(t, expected, isRetryable(fmt.Errorf("wrapped: %w", err)), err.Error())
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		delay := backoffDelay(attempt)
+		assert.LessOrEqual(t, delay, retryMaxDelay)
+		assert.GreaterOrEqual(t, delay, retryBaseDelay/2)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	classes := map[error]error{
+		youtube.ErrInvalidCharactersInVideoID: ErrInvalidLink,
+		youtube.ErrVideoPrivate:               ErrPrivate,
+		youtube.ErrLoginRequired:              ErrAuthRequired,
+		&youtube.ErrPlayabiltyStatus{Status: "UNPLAYABLE", Reason: "Not available in your country"}: ErrGeoBlocked,
+		youtube.ErrUnexpectedStatusCode(429): ErrThrottled,
+	}
+	for err, expected := range classes {
+		assert.ErrorIs(t, classify(fmt.Errorf("wrapped: %w", err)), expected, err.Error())
+	}
+
+	// links without an id fail before a request to YouTube
+	_, err := NewYouTubeDownloader().GetVideo("https://youtu.be/")
+	assert.ErrorIs(t, err, ErrInvalidLink)
+
+	tooLarge := fmt.Errorf("%w: acceptable size is 50.00 Mb", ErrTooLarge)
+	assert.Equal(t, tooLarge, classify(tooLarge))
+	assert.ErrorIs(t, ffmpegError("merge", errors.New("exit status 1")), ErrFFmpeg)
+	assert.NoError(t, classify(nil))
+}
+
+func TestParseCookieLine(t *testing.T) {
+	cookie, host, err := parseCookieLine(".youtube.com\tTRUE\t/\tTRUE\t1735689600\tSID\tsecret")
+	assert.NoError(t, err)
+	assert.Equal(t, ".youtube.com", cookie.Domain)
+	assert.Equal(t, "youtube.com", host)
+	assert.Equal(t, "SID", cookie.Name)
+	assert.Equal(t, "secret", cookie.Value)
+	assert.True(t, cookie.Secure)
+
+	cookie, host, err = parseCookieLine("www.youtube.com\tFALSE\t/\tTRUE\t0\t__Host-GAPS\thost")
+	assert.NoError(t, err)
+	assert.Empty(t, cookie.Domain)
+	assert.Equal(t, "www.youtube.com", host)
+
+	_, _, err = parseCookieLine("youtube.com\tFALSE\t/\tFALSE")
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	lines := "# Netscape HTTP Cookie File\n" +
+		".youtube.com\tTRUE\t/\tTRUE\t4102444800\tSID\tsecret\n" +
+		"#HttpOnly_www.youtube.com\tFALSE\t/\tTRUE\t0\t__Host-GAPS\thost\n"
+	assert.NoError(t, os.WriteFile(path, []byte(lines), 0o600))
+
+	jar, err := LoadCookies(path)
+	assert.NoError(t, err)
+	names := func(rawURL string) []string {
+		u, _ := url.Parse(rawURL)
+		var names []string
+		for _, cookie := range jar.Cookies(u) {
+			names = append(names, cookie.Name)
+		}
+		return names
+	}
+	assert.ElementsMatch(t, []string{"SID", "__Host-GAPS"}, names("https://www.youtube.com/watch"))
+	assert.ElementsMatch(t, []string{"SID"}, names("https://music.youtube.com/"))
+}
+
+func TestVideoCache(t *testing.T) {
+	cache := newCache(2, time.Minute, copyVideo)
+	cache.Put("first", &youtube.Video{ID: "first", Formats: youtube.FormatList{{ItagNo: 18}}})
+	cache.Put("second", &youtube.Video{ID: "second"})
+
+	video, ok := cache.Get("first")
+	assert.True(t, ok)
+	video.Formats[0].ItagNo = 22 // a copy is returned, the cached video keeps its formats
+
+	cache.Put("third", &youtube.Video{ID: "third"}) // "second" is the least recently used
+	_, ok = cache.Get("second")
+	assert.False(t, ok)
+
+	video, ok = cache.Get("first")
+	assert.True(t, ok)
+	assert.Equal(t, 18, video.Formats[0].ItagNo)
+
+	expired := newCache(2, -time.Minute, copyVideo)
+	expired.Put("first", &youtube.Video{ID: "first"})
+	_, ok = expired.Get("first")
+	assert.False(t, ok)
+}
+
+func TestPlaylistCache(t *testing.T) {
+	link := "https://www.youtube.com/playlist?list=PLcached"
+	playlists.Put(link, &youtube.Playlist{ID: "PLcached", Videos: []*youtube.PlaylistEntry{{ID: "first"}, {ID: "second"}}})
+	defer playlists.Remove(link)
+
+	ytd := NewYouTubeDownloader()
+	playlist, err := ytd.GetPlaylist(link) // a cached playlist isn't fetched
+	assert.NoError(t, err)
+	assert.Equal(t, "PLcached", playlist.ID)
+	playlist.Videos = playlist.Videos[:1] // a copy is returned, the cached playlist keeps its entries
+
+	playlist, err = ytd.GetPlaylist(link)
+	assert.NoError(t, err)
+	assert.Len(t, playlist.Videos, 2)
+
+	channelURL := "https://www.youtube.com/@cached"
+	channelIDs.Put(channelURL, "UCcached")
+	defer channelIDs.Remove(channelURL)
+	uploadsURL := UploadsPlaylistURL("UCcached")
+	playlists.Put(uploadsURL, &youtube.Playlist{ID: "UUcached"})
+	defer playlists.Remove(uploadsURL)
+
+	uploads, err := ytd.GetChannelUploads(context.Background(), channelURL) // the channel isn't resolved again
+	assert.NoError(t, err)
+	assert.Equal(t, "UUcached", uploads.ID)
+}
+
+func TestSelectFormat(t *testing.T) {
+	video := &youtube.Video{ID: "x", Formats: youtube.FormatList{
+		{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, Bitrate: 128000, AudioChannels: 2, ContentLength: 1 << 20},
+		{ItagNo: 251, MimeType: `audio/webm; codecs="opus"`, Bitrate: 160000, AudioChannels: 2, ContentLength: 1 << 20},
+		{ItagNo: 299, MimeType: `video/mp4; codecs="avc1"`, QualityLabel: "1080p", Width: 1920, Height: 1080, Bitrate: 4000000, ContentLength: 100 << 20},
+		{ItagNo: 136, MimeType: `video/mp4; codecs="avc1"`, QualityLabel: "720p", Width: 1280, Height: 720, Bitrate: 2000000, ContentLength: 40 << 20},
+		{ItagNo: 135, MimeType: `video/mp4; codecs="avc1"`, QualityLabel: "480p", Width: 854, Height: 480, Bitrate: 1000000, ContentLength: 20 << 20},
+	}}
+
+	for _, tc := range []struct {
+		preference FormatPreference
+		itag       int
+	}{
+		{FormatPreference{Audio: true}, 140},
+		{FormatPreference{Audio: true, AudioMimeType: "audio/webm"}, 251},
+		{FormatPreference{}, 299},
+		{FormatPreference{MaxHeight: 720}, 136},
+		{FormatPreference{MaxHeight: 480}, 135},
+	} {
+		format, err := SelectFormat(video, tc.preference)
+		if assert.NoError(t, err, tc.preference) {
+			assert.Equal(t, tc.itag, format.ItagNo, tc.preference)
+		}
+	}
+
+	_, err := SelectFormat(video, FormatPreference{MaxHeight: 360})
+	assert.ErrorIs(t, err, ErrNoPresetFormat)
+}
@@ -0,0 +1,69 @@
+package locale
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LanguageKey is the key of the language in its translations, plural forms depend on it
+const LanguageKey = "_language"
+
+// Params are values of named placeholders, e.g. {count}
+type Params map[string]any
+
+// Format replaces {name} placeholders of the text by params, unknown placeholders are kept
+func Format(text string, params Params) string {
+	if len(params) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Text return the translation of the key with placeholders replaced by params
+func Text(translations map[string]string, key string, params Params) string {
+	return Format(translations[key], params)
+}
+
+// Plural return the translation of the key in the plural form for n, i.e. of key_one, key_few, key_many or key_other.
+// The {count} placeholder is replaced by n. A missing form is replaced by key_other and then by the key itself
+func Plural(translations map[string]string, key string, n int, params Params) string {
+	text, ok := translations[key+"_"+pluralForm(translations[LanguageKey], n)]
+	if !ok {
+		if text, ok = translations[key+"_other"]; !ok {
+			text = translations[key]
+		}
+	}
+
+	withCount := Params{"count": n}
+	for name, value := range params {
+		withCount[name] = value
+	}
+	return Format(text, withCount)
+}
+
+// pluralForm return the plural form of the number in the language: one, few, many or other
+func pluralForm(lang string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	switch lang {
+	case "ru", "uk", "be":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+}
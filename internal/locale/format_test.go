@@ -0,0 +1,26 @@
+package locale
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "Move 2 up", Format("Move {number} up", Params{"number": 2}))
+	assert.Equal(t, "{unknown} 1", Format("{unknown} {number}", Params{"number": 1}))
+	assert.Equal(t, "no params", Format("no params", nil))
+}
+
+func TestPlural(t *testing.T) {
+	en := map[string]string{LanguageKey: "en", "files_one": "{count} file", "files_other": "{count} files"}
+	assert.Equal(t, "1 file", Plural(en, "files", 1, nil))
+	assert.Equal(t, "5 files", Plural(en, "files", 5, nil))
+
+	ru := map[string]string{LanguageKey: "ru", "files_one": "{count} файл {where}", "files_few": "{count} файла {where}",
+		"files_many": "{count} файлов {where}"}
+	assert.Equal(t, "21 файл в очереди", Plural(ru, "files", 21, Params{"where": "в очереди"}))
+	assert.Equal(t, "3 файла в очереди", Plural(ru, "files", 3, Params{"where": "в очереди"}))
+	assert.Equal(t, "12 файлов в очереди", Plural(ru, "files", 12, Params{"where": "в очереди"}))
+
+	assert.Equal(t, "2 files", Plural(map[string]string{"files": "{count} files"}, "files", 2, nil))
+}
@@ -0,0 +1,132 @@
+package locale
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultLanguage is used for users whose language isn't supported and for keys missing in other languages
+const DefaultLanguage = "en"
+
+// Localizer keeps translations of every language found in the locales directory
+type Localizer struct {
+	defaultLanguage string
+	translations    map[string]map[string]string // complete translations, missing keys are taken from the default language
+	missing         map[string][]string          // keys of the default language missing in other languages
+}
+
+// Load reads every <language>.json file of the directory, the default language must be among them
+func Load(dir string, defaultLanguage string) (*Localizer, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]map[string]string, len(paths))
+	for _, path := range paths {
+		translation, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[strings.TrimSuffix(filepath.Base(path), ".json")] = translation
+	}
+	if _, ok := files[defaultLanguage]; !ok {
+		return nil, fmt.Errorf("no translation file of the default language %q in %s", defaultLanguage, dir)
+	}
+	return New(files, defaultLanguage), nil
+}
+
+// New return a localizer of the translations by languages
+func New(files map[string]map[string]string, defaultLanguage string) *Localizer {
+	l := &Localizer{
+		defaultLanguage: defaultLanguage,
+		translations:    make(map[string]map[string]string, len(files)),
+		missing:         make(map[string][]string),
+	}
+	defaults := files[defaultLanguage]
+	for lang, translation := range files {
+		complete := make(map[string]string, len(defaults))
+		for key, text := range defaults {
+			value, ok := translation[key]
+			if !ok || value == "" {
+				l.missing[lang] = append(l.missing[lang], key)
+				value = text
+			}
+			complete[key] = value
+		}
+		for key, text := range translation {
+			if _, ok := complete[key]; !ok {
+				complete[key] = text
+			}
+		}
+		sort.Strings(l.missing[lang])
+		complete[LanguageKey] = lang
+		l.translations[lang] = complete
+	}
+	return l
+}
+
+// readFile return translations of the file by keys
+func readFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open translation file: %v", err)
+	}
+	defer file.Close()
+
+	var translation map[string]string
+	if err := json.NewDecoder(file).Decode(&translation); err != nil {
+		return nil, fmt.Errorf("could not decode translation file %s: %v", path, err)
+	}
+	return translation, nil
+}
+
+// Language return the supported language for the language code of the user, e.g. "pt" for "pt-br",
+// and the default language for an unknown one
+func (l *Localizer) Language(code string) string {
+	code = strings.ToLower(code)
+	if _, ok := l.translations[code]; ok {
+		return code
+	}
+	if base, _, ok := strings.Cut(code, "-"); ok {
+		if _, ok := l.translations[base]; ok {
+			return base
+		}
+	}
+	return l.defaultLanguage
+}
+
+// DefaultLanguage return the language used for unsupported languages and missing keys
+func (l *Localizer) DefaultLanguage() string {
+	return l.defaultLanguage
+}
+
+// Supports return true if there is a translation file of the language
+func (l *Localizer) Supports(lang string) bool {
+	_, ok := l.translations[lang]
+	return ok
+}
+
+// Translations return translations of the language by keys, every key of the default language is present
+func (l *Localizer) Translations(code string) map[string]string {
+	return l.translations[l.Language(code)]
+}
+
+// Languages return supported languages in alphabetical order
+func (l *Localizer) Languages() []string {
+	languages := make([]string, 0, len(l.translations))
+	for lang := range l.translations {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Missing return keys of the default language which other languages don't translate
+func (l *Localizer) Missing() map[string][]string {
+	return l.missing
+}
@@ -0,0 +1,38 @@
+package locale
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalizer(t *testing.T) {
+	l := New(map[string]map[string]string{
+		"en": {"hello": "Hello", "bye": "Bye"},
+		"ru": {"hello": "Привет", "extra": "Ещё"},
+	}, "en")
+
+	assert.Equal(t, []string{"en", "ru"}, l.Languages())
+	assert.Equal(t, "Привет", l.Translations("ru")["hello"])
+	assert.Equal(t, "Bye", l.Translations("ru")["bye"])
+	assert.Equal(t, "Ещё", l.Translations("ru")["extra"])
+	assert.Equal(t, "Привет", l.Translations("RU-ru")["hello"])
+	assert.Equal(t, "Hello", l.Translations("de")["hello"])
+	assert.Equal(t, "Hello", l.Translations("")["hello"])
+	assert.Equal(t, map[string][]string{"ru": {"bye"}}, l.Missing())
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "en.json"), []byte(`{"hello": "Hello"}`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "uk.json"), []byte(`{"hello": "Привіт"}`), 0o644))
+
+	l, err := Load(dir, "en")
+	assert.NoError(t, err)
+	assert.True(t, l.Supports("uk"))
+	assert.Equal(t, "Привіт", l.Translations("uk")["hello"])
+
+	_, err = Load(dir, "fr")
+	assert.Error(t, err)
+}
@@ -0,0 +1,521 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// State is a stage of a job's life
+type State string
+
+const (
+	StateQueued      State = "queued"
+	StateDownloading State = "downloading"
+	StateMerging     State = "merging"
+	StateUploading   State = "uploading"
+	StateDone        State = "done"
+	StateFailed      State = "failed"
+	StateCanceled    State = "canceled"
+)
+
+// Job is a unit of work of a user, i.e. downloading and sending a file
+type Job struct {
+	ID     string
+	UserID int64
+	ChatID int64
+	Title  string // shown to the user
+	Class  string // jobs of a class with own workers run only on them, see AddWorkers
+	Run    func(ctx context.Context, job *Job) error
+	// OnCanceled is called if the job is canceled before it started, it may be nil
+	OnCanceled func(job *Job)
+
+	mu          sync.Mutex
+	state       State
+	progress    float64 // percents of the current state
+	createdAt   time.Time
+	startedAt   time.Time
+	cancel      context.CancelFunc // cancels the running job
+	canceled    bool
+	interrupted bool // stopped by Shutdown, the job stays unfinished
+
+	onStateChange func(job *Job, state State)
+}
+
+// JobInfo is a snapshot of a job shown to its user
+type JobInfo struct {
+	ID       string
+	Title    string
+	State    State
+	Progress float64
+	Position int           // 0 for a running job
+	Wait     time.Duration // estimated time before a queued job starts
+}
+
+// defaultJobDuration is the estimated duration of a job until some jobs are done
+const defaultJobDuration = time.Minute
+
+// State return the current state of the job
+func (j *Job) State() State {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// SetState moves the job to the state and resets its progress
+func (j *Job) SetState(state State) {
+	j.mu.Lock()
+	j.state = state
+	j.progress = 0
+	onStateChange := j.onStateChange
+	j.mu.Unlock()
+
+	if onStateChange != nil {
+		onStateChange(j, state)
+	}
+}
+
+// Interrupted return true if the job was stopped by Shutdown before it finished
+func (j *Job) Interrupted() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.interrupted
+}
+
+// Progress return percents of the current state done
+func (j *Job) Progress() float64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
+
+// SetProgress sets percents of the current state done
+func (j *Job) SetProgress(progress float64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.progress = progress
+}
+
+// Queue runs jobs by a pool of workers.
+// Users take turns, so one user's playlist doesn't hold up others,
+// and every user runs at most userLimit jobs at once
+type Queue struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	workers   int
+	userLimit int
+	pending   map[int64][]*Job
+	users     []int64 // users with pending jobs in order of their turn
+	running   map[int64]int
+	active    map[string]*Job // running jobs by id
+	avgTime   time.Duration   // moving average duration of jobs
+	idPrefix  string          // ids don't repeat after a restart
+	closed    bool            // no jobs are taken after Shutdown
+	classes   map[string]int  // workers of classes of jobs which don't take the common workers
+	nextID    int64
+	wg        sync.WaitGroup
+
+	onStateChange func(job *Job, state State)
+}
+
+// New return a queue with the number of workers and the limit of concurrent jobs per user
+func New(workers, userLimit int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	if userLimit < 1 {
+		userLimit = 1
+	}
+	q := &Queue{
+		workers:   workers,
+		userLimit: userLimit,
+		pending:   make(map[int64][]*Job),
+		running:   make(map[int64]int),
+		active:    make(map[string]*Job),
+		classes:   make(map[string]int),
+		avgTime:   defaultJobDuration,
+		idPrefix:  strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// OnStateChange sets a function called on every state change of jobs enqueued after it
+func (q *Queue) OnStateChange(f func(job *Job, state State)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onStateChange = f
+}
+
+// NewID return a unique id for a job
+func (q *Queue) NewID() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.newID()
+}
+
+func (q *Queue) newID() string {
+	q.nextID++
+	return q.idPrefix + "-" + strconv.FormatInt(q.nextID, 10)
+}
+
+// AddWorkers gives jobs of the class their own workers, so long jobs, i.e. recordings, don't hold up others.
+// It's called before Start
+func (q *Queue) AddWorkers(class string, workers int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.classes[class] = max(workers, 1)
+}
+
+// Start starts workers, they stop taking new jobs when ctx is done
+func (q *Queue) Start(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx, "")
+	}
+	for class, workers := range q.classes {
+		for i := 0; i < workers; i++ {
+			q.wg.Add(1)
+			go q.worker(ctx, class)
+		}
+	}
+}
+
+// classOf return the class of workers running the job, "" for the common workers
+func (q *Queue) classOf(job *Job) string {
+	if _, ok := q.classes[job.Class]; ok {
+		return job.Class
+	}
+	return ""
+}
+
+// Wait blocks until all workers stop
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// Shutdown stops taking queued jobs and waits for running ones.
+// When ctx is done, running jobs are interrupted without a final state, so they are resumed after a restart,
+// and ctx.Err() is returned. Queued jobs stay in the queue
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	for _, job := range q.active {
+		job.mu.Lock()
+		job.interrupted = true
+		job.mu.Unlock()
+		job.cancel()
+	}
+	q.mu.Unlock()
+
+	<-done
+	return ctx.Err()
+}
+
+// Enqueue adds the job to the queue and returns its position
+func (q *Queue) Enqueue(job *Job) int {
+	q.mu.Lock()
+	if job.ID == "" {
+		job.ID = q.newID()
+	}
+	onStateChange := q.onStateChange
+	q.mu.Unlock()
+
+	// the state is saved before workers can take the job, and the queue isn't locked while it's saved
+	job.createdAt = time.Now()
+	job.onStateChange = onStateChange
+	job.SetState(StateQueued)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending[job.UserID]) == 0 {
+		q.users = append(q.users, job.UserID)
+	}
+	q.pending[job.UserID] = append(q.pending[job.UserID], job)
+	// workers wait for jobs of their class
+	q.cond.Broadcast()
+
+	return q.position(job.UserID, len(q.pending[job.UserID])-1)
+}
+
+// NextPosition return the position a new job of the user would get
+func (q *Queue) NextPosition(userID int64) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.position(userID, len(q.pending[userID]))
+}
+
+// position estimates how many jobs run before the index-th pending job of the user plus one.
+// Users ahead in the turn order run index+1 jobs before it, users behind run index jobs
+func (q *Queue) position(userID int64, index int) int {
+	ahead := index
+	behind := false
+	for _, user := range q.users {
+		if user == userID {
+			behind = true
+			continue
+		}
+		turns := index
+		if !behind {
+			turns++
+		}
+		ahead += min(len(q.pending[user]), turns)
+	}
+	return ahead + 1
+}
+
+// Len return the number of running and queued jobs
+func (q *Queue) Len() (running, queued int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, jobs := range q.pending {
+		queued += len(jobs)
+	}
+	return len(q.active), queued
+}
+
+// UserJobs return running and queued jobs of the user, running ones go first
+func (q *Queue) UserJobs(userID int64) []JobInfo {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var jobs []JobInfo
+	for _, job := range q.active {
+		if job.UserID == userID {
+			jobs = append(jobs, JobInfo{ID: job.ID, Title: job.Title, State: job.State(), Progress: job.Progress()})
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return q.active[jobs[i].ID].startedAt.Before(q.active[jobs[j].ID].startedAt) })
+
+	for i, job := range q.pending[userID] {
+		position := q.position(userID, i)
+		jobs = append(jobs, JobInfo{
+			ID:       job.ID,
+			Title:    job.Title,
+			State:    StateQueued,
+			Position: position,
+			Wait:     q.wait(position),
+		})
+	}
+	return jobs
+}
+
+// wait estimates the time before the job at the position starts.
+// Every worker is busy with a running job or one of jobs ahead for the average duration of a job
+func (q *Queue) wait(position int) time.Duration {
+	rounds := (len(q.active) + position - 1) / q.workers
+	return time.Duration(rounds) * q.avgTime
+}
+
+// Cancel cancels the queued or running job of the user and returns false if there is no such job
+func (q *Queue) Cancel(userID int64, id string) bool {
+	job, queued := q.cancel(userID, id)
+	if !queued {
+		return job != nil
+	}
+
+	// the state is saved after the queue is unlocked
+	job.SetState(StateCanceled)
+	if job.OnCanceled != nil {
+		go job.OnCanceled(job)
+	}
+	return true
+}
+
+// cancel cancels the running job or removes the queued one, queued is true for a removed job
+func (q *Queue) cancel(userID int64, id string) (job *Job, queued bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job, ok := q.active[id]; ok && job.UserID == userID {
+		job.mu.Lock()
+		job.canceled = true
+		job.mu.Unlock()
+		job.cancel()
+		return job, false
+	}
+
+	for i, job := range q.pending[userID] {
+		if job.ID != id {
+			continue
+		}
+		q.pending[userID] = append(q.pending[userID][:i:i], q.pending[userID][i+1:]...)
+		if len(q.pending[userID]) == 0 {
+			q.removeUser(userID)
+		}
+		return job, true
+	}
+	return nil, false
+}
+
+// MoveUp moves the queued job of the user ahead of the user's previous job.
+// It returns false if there is no such job or it's the user's first one
+func (q *Queue) MoveUp(userID int64, id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := q.pending[userID]
+	for i := 1; i < len(jobs); i++ {
+		if jobs[i].ID == id {
+			jobs[i-1], jobs[i] = jobs[i], jobs[i-1]
+			return true
+		}
+	}
+	return false
+}
+
+// removeUser removes the user without pending jobs from the turn order
+func (q *Queue) removeUser(userID int64) {
+	delete(q.pending, userID)
+	for i, user := range q.users {
+		if user == userID {
+			q.users = append(q.users[:i:i], q.users[i+1:]...)
+			return
+		}
+	}
+}
+
+// worker takes jobs of the class and runs them until ctx is done
+func (q *Queue) worker(ctx context.Context, class string) {
+	defer q.wg.Done()
+	for {
+		job, jobCtx := q.take(ctx, class)
+		if job == nil {
+			return
+		}
+		q.run(jobCtx, job)
+	}
+}
+
+// take blocks until there is a job of the class of a user under the limit, removes it from the queue
+// and makes it active, so Shutdown can interrupt it before it runs. The job's context is canceled by Cancel and Shutdown.
+// It returns nil when ctx is done
+func (q *Queue) take(ctx context.Context, class string) (*Job, context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if ctx.Err() != nil || q.closed {
+			return nil, nil
+		}
+
+		for i, user := range q.users {
+			if q.running[user] >= q.userLimit {
+				continue
+			}
+
+			index := -1
+			for j, job := range q.pending[user] {
+				if q.classOf(job) == class {
+					index = j
+					break
+				}
+			}
+			if index < 0 {
+				continue
+			}
+
+			job := q.pending[user][index]
+			q.pending[user] = append(q.pending[user][:index:index], q.pending[user][index+1:]...)
+			q.running[user]++
+
+			// the user goes to the end of the turn order
+			q.users = append(q.users[:i:i], q.users[i+1:]...)
+			if len(q.pending[user]) > 0 {
+				q.users = append(q.users, user)
+			} else {
+				delete(q.pending, user)
+			}
+
+			jobCtx, cancel := context.WithCancel(ctx)
+			job.mu.Lock()
+			job.startedAt = time.Now()
+			job.cancel = cancel
+			job.mu.Unlock()
+			q.active[job.ID] = job
+			return job, jobCtx
+		}
+
+		q.cond.Wait()
+	}
+}
+
+// run runs the active job and lets the next job of the user be taken
+func (q *Queue) run(ctx context.Context, job *Job) {
+	defer job.cancel()
+
+	defer func() {
+		q.mu.Lock()
+		delete(q.active, job.ID)
+		// jobs with own workers don't take the time of common ones
+		if job.State() == StateDone && q.classOf(job) == "" {
+			q.avgTime = (4*q.avgTime + time.Since(job.startedAt)) / 5
+		}
+		q.running[job.UserID]--
+		if q.running[job.UserID] == 0 {
+			delete(q.running, job.UserID)
+		}
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}()
+	// a panicking job fails alone, the worker goes on
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s of user %d panicked: %v\n%s", job.ID, job.UserID, r, debug.Stack())
+			job.SetState(StateFailed)
+		}
+	}()
+
+	log.Printf("Job %s of user %d started: %s", job.ID, job.UserID, job.Title)
+	err := job.Run(ctx, job)
+
+	job.mu.Lock()
+	canceled, interrupted := job.canceled, job.interrupted
+	job.mu.Unlock()
+	if interrupted {
+		log.Printf("Job %s of user %d is interrupted by shutdown", job.ID, job.UserID)
+		return
+	}
+	if canceled {
+		job.SetState(StateCanceled)
+		log.Printf("Job %s of user %d canceled", job.ID, job.UserID)
+		return
+	}
+	if err != nil {
+		job.SetState(StateFailed)
+		log.Printf("Job %s of user %d failed: %s", job.ID, job.UserID, err)
+		return
+	}
+	job.SetState(StateDone)
+	log.Printf("Job %s of user %d done", job.ID, job.UserID)
+}
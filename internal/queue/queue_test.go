@@ -0,0 +1,203 @@
+package queue
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPosition(t *testing.T) {
+	q := New(1, 1)
+
+	assert.Equal(t, 1, q.Enqueue(&Job{UserID: 1}))
+	assert.Equal(t, 2, q.Enqueue(&Job{UserID: 1}))
+	// the second user goes right after the first job of the first user
+	assert.Equal(t, 2, q.Enqueue(&Job{UserID: 2}))
+	assert.Equal(t, 4, q.NextPosition(1))
+	assert.Equal(t, 4, q.NextPosition(2))
+	assert.Equal(t, 3, q.NextPosition(3))
+
+	running, queued := q.Len()
+	assert.Equal(t, 0, running)
+	assert.Equal(t, 3, queued)
+}
+
+func TestFairScheduling(t *testing.T) {
+	q := New(1, 1)
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	add := func(userID int64, title string) {
+		wg.Add(1)
+		q.Enqueue(&Job{UserID: userID, Title: title, Run: func(ctx context.Context, job *Job) error {
+			defer wg.Done()
+			mu.Lock()
+			order = append(order, job.Title)
+			mu.Unlock()
+			return nil
+		}})
+	}
+	add(1, "a1")
+	add(1, "a2")
+	add(1, "a3")
+	add(2, "b1")
+	add(3, "c1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Start(ctx)
+	wg.Wait()
+	cancel()
+	q.Wait()
+
+	assert.Equal(t, []string{"a1", "b1", "c1", "a2", "a3"}, order)
+}
+
+func TestUserLimit(t *testing.T) {
+	q := New(3, 1)
+
+	release := make(chan struct{})
+	started := make(chan int64, 3)
+	for _, userID := range []int64{1, 1, 2} {
+		q.Enqueue(&Job{UserID: userID, Run: func(ctx context.Context, job *Job) error {
+			started <- job.UserID
+			<-release
+			return nil
+		}})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	// the second job of the first user waits for the first one despite free workers
+	first, second := <-started, <-started
+	assert.ElementsMatch(t, []int64{1, 2}, []int64{first, second})
+	select {
+	case userID := <-started:
+		t.Fatalf("job of user %d started over the limit", userID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, int64(1), <-started)
+}
+
+func TestCancelAndMoveUp(t *testing.T) {
+	q := New(1, 1)
+	for _, id := range []string{"a", "b", "c"} {
+		q.Enqueue(&Job{ID: id, UserID: 1})
+	}
+
+	assert.True(t, q.MoveUp(1, "c"))
+	assert.False(t, q.MoveUp(1, "a"))
+	assert.False(t, q.Cancel(2, "b"))
+	assert.True(t, q.Cancel(1, "b"))
+
+	var ids []string
+	for _, job := range q.UserJobs(1) {
+		ids = append(ids, job.ID)
+	}
+	assert.Equal(t, []string{"a", "c"}, ids)
+}
+
+func TestCancelRunning(t *testing.T) {
+	q := New(1, 1)
+	started := make(chan struct{})
+	job := &Job{ID: "a", UserID: 1, Run: func(ctx context.Context, job *Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	q.Enqueue(job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	<-started
+	assert.Equal(t, StateQueued, q.UserJobs(1)[0].State)
+	assert.True(t, q.Cancel(1, "a"))
+	assert.Eventually(t, func() bool { return job.State() == StateCanceled }, time.Second, 10*time.Millisecond)
+}
+
+func TestShutdown(t *testing.T) {
+	q := New(1, 1)
+	started := make(chan struct{})
+	running := &Job{ID: "a", UserID: 1, Run: func(ctx context.Context, job *Job) error {
+		job.SetState(StateDownloading)
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	queued := &Job{ID: "b", UserID: 1}
+	q.Enqueue(running)
+	q.Enqueue(queued)
+	q.Start(context.Background())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Shutdown(ctx), context.DeadlineExceeded)
+
+	// both jobs stay unfinished to be resumed after a restart
+	assert.True(t, running.Interrupted())
+	assert.Equal(t, StateDownloading, running.State())
+	assert.Equal(t, StateQueued, queued.State())
+}
+
+func TestPanickingJob(t *testing.T) {
+	q := New(1, 1)
+	done := make(chan struct{})
+	panicking := &Job{ID: "a", UserID: 1, Run: func(ctx context.Context, job *Job) error {
+		var message *struct{ ChatID int64 }
+		_ = message.ChatID
+		return nil
+	}}
+	next := &Job{ID: "b", UserID: 1, Run: func(ctx context.Context, job *Job) error {
+		close(done)
+		return nil
+	}}
+	q.Enqueue(panicking)
+	q.Enqueue(next)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	// the worker takes the next job after the panic
+	<-done
+	assert.Equal(t, StateFailed, panicking.State())
+}
+
+func TestClassWorkers(t *testing.T) {
+	q := New(1, 2)
+	q.AddWorkers("recording", 1)
+	release := make(chan struct{})
+	started := make(chan string, 3)
+	run := func(ctx context.Context, job *Job) error {
+		started <- job.ID
+		<-release
+		return nil
+	}
+	q.Enqueue(&Job{ID: "recording", UserID: 1, Class: "recording", Run: run})
+	q.Enqueue(&Job{ID: "second recording", UserID: 2, Class: "recording", Run: run})
+	q.Enqueue(&Job{ID: "download", UserID: 3, Run: run})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	// the recording doesn't take the common worker, the second one waits for the recording worker
+	first, second := <-started, <-started
+	assert.ElementsMatch(t, []string{"recording", "download"}, []string{first, second})
+	select {
+	case id := <-started:
+		t.Fatalf("job %s started without a free worker", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, "second recording", <-started)
+}
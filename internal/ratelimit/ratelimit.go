@@ -0,0 +1,207 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is the number of requests allowed for a period, all of them may be made at once
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses a limit like "10/1m", which allows 10 requests a minute
+func ParseLimit(s string) (Limit, error) {
+	requests, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, expected <requests>/<period>", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid number of requests in rate limit %q", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid period in rate limit %q", s)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// bucket is a token bucket refilled by a token every period/requests
+type bucket struct {
+	limit    Limit
+	tokens   float64
+	updated  time.Time
+	notified time.Time // the user is told about the limit once until this time
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{limit: limit, tokens: float64(limit.Requests), updated: now}
+}
+
+// refill adds tokens for the time passed since the last update
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += float64(b.limit.Requests) * float64(elapsed) / float64(b.limit.Period)
+	if b.tokens > float64(b.limit.Requests) {
+		b.tokens = float64(b.limit.Requests)
+	}
+	b.updated = now
+}
+
+// wait return the time until there is a token, it's 0 if there is one
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(b.limit.Period) / float64(b.limit.Requests))
+}
+
+// full return true if the bucket is refilled up to the limit by now
+func (b *bucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= float64(b.limit.Requests)
+}
+
+// Limiter limits requests of every user by the limit of the user's tier and requests of all users by the global limit
+type Limiter struct {
+	mu          sync.Mutex
+	global      *bucket
+	tiers       map[string]Limit
+	defaultTier string
+	users       map[int64]*bucket
+	cleaned     time.Time
+	now         func() time.Time
+}
+
+// cleanupInterval is how often buckets of idle users are removed
+const cleanupInterval = 10 * time.Minute
+
+// New return a limiter with the global limit and limits of tiers.
+// Users of unknown tiers get the limit of defaultTier
+func New(global Limit, tiers map[string]Limit, defaultTier string) *Limiter {
+	l := &Limiter{
+		tiers:       tiers,
+		defaultTier: defaultTier,
+		users:       make(map[int64]*bucket),
+		now:         time.Now,
+	}
+	l.cleaned = l.now()
+	l.global = newBucket(global, l.cleaned)
+	return l
+}
+
+// Allow takes a token of the user and a global one.
+// It returns 0 if the request is allowed or the time to wait before the next request
+func (l *Limiter) Allow(userID int64, tier string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+
+	limit, ok := l.tiers[tier]
+	if !ok {
+		limit = l.tiers[l.defaultTier]
+	}
+	user, ok := l.users[userID]
+	if !ok || user.limit != limit {
+		user = newBucket(limit, now)
+		l.users[userID] = user
+	}
+
+	user.refill(now)
+	l.global.refill(now)
+	if wait := max(user.wait(), l.global.wait()); wait > 0 {
+		return wait
+	}
+	user.tokens--
+	l.global.tokens--
+	return 0
+}
+
+// ShouldNotify return true if the throttled user wasn't told about the limit during the last wait.
+// So a user spamming requests gets a single message instead of a message per request
+func (l *Limiter) ShouldNotify(userID int64, wait time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	user, ok := l.users[userID]
+	if !ok {
+		return true
+	}
+	now := l.now()
+	if now.Before(user.notified) {
+		return false
+	}
+	user.notified = now.Add(wait)
+	return true
+}
+
+// cleanup removes buckets of users who are idle long enough to have them full
+func (l *Limiter) cleanup(now time.Time) {
+	if now.Sub(l.cleaned) < cleanupInterval {
+		return
+	}
+	l.cleaned = now
+	for userID, user := range l.users {
+		if user.full(now) && !now.Before(user.notified) {
+			delete(l.users, userID)
+		}
+	}
+}
+
+// Throttle spaces out requests of a single sender by the limit, i.e. messages of a broadcast
+type Throttle struct {
+	mu     sync.Mutex
+	bucket *bucket
+	now    func() time.Time
+}
+
+// NewThrottle return a throttle allowing requests by the limit
+func NewThrottle(limit Limit) *Throttle {
+	t := &Throttle{now: time.Now}
+	t.bucket = newBucket(limit, t.now())
+	return t
+}
+
+// Wait blocks until a request is allowed or ctx is done
+func (t *Throttle) Wait(ctx context.Context) error {
+	for {
+		t.mu.Lock()
+		now := t.now()
+		t.bucket.refill(now)
+		wait := t.bucket.wait()
+		if wait == 0 {
+			t.bucket.tokens--
+		}
+		t.mu.Unlock()
+
+		if wait == 0 {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Pause empties the throttle for the duration, i.e. when Telegram asks to retry after it
+func (t *Throttle) Pause(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.bucket.refill(t.now())
+	t.bucket.tokens = -float64(t.bucket.limit.Requests) * float64(d) / float64(t.bucket.limit.Period)
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("10/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 10, Period: time.Minute}, limit)
+
+	for _, s := range []string{"10", "0/1m", "x/1m", "10/x", "10/-1s"} {
+		_, err := ParseLimit(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	now := time.Now()
+	l := New(Limit{Requests: 100, Period: time.Second}, map[string]Limit{
+		"inactive": {Requests: 2, Period: time.Minute},
+		"active":   {Requests: 4, Period: time.Minute},
+	}, "inactive")
+	l.now = func() time.Time { return now }
+
+	assert.Zero(t, l.Allow(1, "inactive"))
+	assert.Zero(t, l.Allow(1, "unknown"))
+	assert.Equal(t, 30*time.Second, l.Allow(1, "inactive"))
+	// other users and tiers have their own buckets
+	for i := 0; i < 4; i++ {
+		assert.Zero(t, l.Allow(2, "active"))
+	}
+
+	now = now.Add(30 * time.Second)
+	assert.Zero(t, l.Allow(1, "inactive"))
+	assert.NotZero(t, l.Allow(1, "inactive"))
+}
+
+func TestGlobalLimit(t *testing.T) {
+	now := time.Now()
+	l := New(Limit{Requests: 2, Period: time.Second}, map[string]Limit{
+		"inactive": {Requests: 10, Period: time.Minute},
+	}, "inactive")
+	l.now = func() time.Time { return now }
+
+	assert.Zero(t, l.Allow(1, "inactive"))
+	assert.Zero(t, l.Allow(2, "inactive"))
+	assert.Equal(t, 500*time.Millisecond, l.Allow(3, "inactive"))
+}
+
+func TestShouldNotify(t *testing.T) {
+	now := time.Now()
+	l := New(Limit{Requests: 100, Period: time.Second}, map[string]Limit{
+		"inactive": {Requests: 1, Period: time.Minute},
+	}, "inactive")
+	l.now = func() time.Time { return now }
+
+	l.Allow(1, "inactive")
+	wait := l.Allow(1, "inactive")
+	assert.True(t, l.ShouldNotify(1, wait))
+	assert.False(t, l.ShouldNotify(1, wait))
+
+	now = now.Add(wait)
+	assert.True(t, l.ShouldNotify(1, wait))
+}
+
+func TestThrottle(t *testing.T) {
+	th := NewThrottle(Limit{Requests: 2, Period: 100 * time.Millisecond})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, th.Wait(ctx))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	th.Pause(time.Hour)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, th.Wait(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Ban is a banned user by a Telegram user id or a username
+type Ban struct {
+	Target    string
+	Reason    string
+	AdminID   int64
+	CreatedAt time.Time
+}
+
+// AuditEntry is a record of an admin's action
+type AuditEntry struct {
+	ID            int64
+	AdminID       int64
+	AdminUsername string
+	Action        string
+	Target        string
+	Details       string
+	CreatedAt     time.Time
+}
+
+// JobStats is the number of jobs created since a time by their states
+type JobStats struct {
+	Users  int
+	Jobs   int
+	States map[string]int
+}
+
+// AddBan bans the target or updates the reason of its ban
+func (s *Store) AddBan(ctx context.Context, ban *Ban) error {
+	if ban.CreatedAt.IsZero() {
+		ban.CreatedAt = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO bans (target, reason, admin_id, created_at) VALUES (?, ?, ?, ?)`,
+		ban.Target, ban.Reason, ban.AdminID, ban.CreatedAt.Unix())
+	return err
+}
+
+// RemoveBan unbans the target and returns false if it wasn't banned
+func (s *Store) RemoveBan(ctx context.Context, target string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM bans WHERE target = ?`, target)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Bans return all bans
+func (s *Store) Bans(ctx context.Context) ([]Ban, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT target, reason, admin_id, created_at FROM bans ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []Ban
+	for rows.Next() {
+		var ban Ban
+		var createdAt int64
+		if err := rows.Scan(&ban.Target, &ban.Reason, &ban.AdminID, &createdAt); err != nil {
+			return nil, err
+		}
+		ban.CreatedAt = time.Unix(createdAt, 0)
+		bans = append(bans, ban)
+	}
+	return bans, rows.Err()
+}
+
+// AddAuditEntry records the admin's action
+func (s *Store) AddAuditEntry(ctx context.Context, entry *AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	res, err := s.db.ExecContext(ctx, `INSERT INTO audit_log
+		(admin_id, admin_username, action, target, details, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.AdminID, entry.AdminUsername, entry.Action, entry.Target, entry.Details, entry.CreatedAt.Unix())
+	if err != nil {
+		return err
+	}
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+// AuditLog return the latest limit records of admins' actions, the latest goes first
+func (s *Store) AuditLog(ctx context.Context, limit int) ([]AuditEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, admin_id, admin_username, action, target, details, created_at
+		FROM audit_log ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var createdAt int64
+		err := rows.Scan(&entry.ID, &entry.AdminID, &entry.AdminUsername, &entry.Action, &entry.Target,
+			&entry.Details, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.CreatedAt = time.Unix(createdAt, 0)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// JobStatsSince return the number of users and their jobs created since the time.
+// Finished jobs are kept for a limited time, so older stats aren't available
+func (s *Store) JobStatsSince(ctx context.Context, since time.Time) (JobStats, error) {
+	stats := JobStats{States: make(map[string]int)}
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT user_id), COUNT(*) FROM jobs WHERE created_at >= ?`,
+		since.Unix()).Scan(&stats.Users, &stats.Jobs)
+	if err != nil {
+		return stats, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT state, COUNT(*) FROM jobs WHERE created_at >= ? GROUP BY state`,
+		since.Unix())
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var state string
+		var n int
+		if err := rows.Scan(&state, &n); err != nil {
+			return stats, err
+		}
+		stats.States[state] = n
+	}
+	return stats, rows.Err()
+}
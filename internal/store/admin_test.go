@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBans(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	assert.NoError(t, s.AddBan(ctx, &Ban{Target: "spammer", Reason: "spam", AdminID: 1}))
+	assert.NoError(t, s.AddBan(ctx, &Ban{Target: "42", AdminID: 1}))
+
+	removed, err := s.RemoveBan(ctx, "42")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = s.RemoveBan(ctx, "42")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+
+	bans, err := s.Bans(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, bans, 1) {
+		assert.Equal(t, "spammer", bans[0].Target)
+		assert.Equal(t, "spam", bans[0].Reason)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, action := range []string{"grant", "ban", "unban"} {
+		assert.NoError(t, s.AddAuditEntry(ctx, &AuditEntry{AdminID: 1, AdminUsername: "admin", Action: action, Target: "user"}))
+	}
+
+	entries, err := s.AuditLog(ctx, 2)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "unban", entries[0].Action)
+		assert.Equal(t, "ban", entries[1].Action)
+	}
+}
+
+func TestJobStats(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	assert.NoError(t, s.SaveJob(ctx, &Job{ID: "1", UserID: 10, State: "done"}))
+	assert.NoError(t, s.SaveJob(ctx, &Job{ID: "2", UserID: 10, State: "failed"}))
+	assert.NoError(t, s.SaveJob(ctx, &Job{ID: "3", UserID: 20, State: "done"}))
+	assert.NoError(t, s.SaveJob(ctx, &Job{ID: "4", UserID: 30, State: "done", CreatedAt: time.Now().Add(-48 * time.Hour)}))
+
+	stats, err := s.JobStatsSince(ctx, time.Now().Add(-24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stats.Users)
+	assert.Equal(t, 3, stats.Jobs)
+	assert.Equal(t, map[string]int{"done": 2, "failed": 1}, stats.States)
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrCallbackNotFound is returned for a token which is unknown or expired
+var ErrCallbackNotFound = errors.New("callback not found")
+
+// Callback is a payload of an inline keyboard button kept under a short token,
+// since Telegram limits callback data to 64 bytes
+type Callback struct {
+	Token       string
+	Action      string
+	URL         string
+	VideoID     string
+	Itag        int
+	Value       string // an action specific value, e.g. a page number or a mime type prefix
+	RequesterID int64  // the user the keyboard was sent to
+	ExpiresAt   time.Time
+}
+
+// SaveCallbacks saves payloads of all buttons of a keyboard at once
+func (s *Store) SaveCallbacks(ctx context.Context, callbacks []*Callback) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, callback := range callbacks {
+		_, err := tx.ExecContext(ctx, `INSERT INTO callbacks
+			(token, action, url, video_id, itag, value, requester_id, created_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			callback.Token, callback.Action, callback.URL, callback.VideoID, callback.Itag, callback.Value,
+			callback.RequesterID, now, callback.ExpiresAt.Unix())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Callback return the payload saved under the token, ErrCallbackNotFound if it's unknown or expired
+func (s *Store) Callback(ctx context.Context, token string) (*Callback, error) {
+	callback := Callback{Token: token}
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx, `SELECT action, url, video_id, itag, value, requester_id, expires_at
+		FROM callbacks WHERE token = ? AND expires_at > ?`, token, time.Now().Unix()).
+		Scan(&callback.Action, &callback.URL, &callback.VideoID, &callback.Itag, &callback.Value,
+			&callback.RequesterID, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCallbackNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	callback.ExpiresAt = time.Unix(expiresAt, 0)
+	return &callback, nil
+}
+
+// DeleteExpiredCallbacks deletes payloads expired before the time and return their number
+func (s *Store) DeleteExpiredCallbacks(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM callbacks WHERE expires_at <= ?`, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCallbacks(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	assert.NoError(t, s.SaveCallbacks(ctx, []*Callback{
+		{Token: "a", Action: "format", URL: "https://youtu.be/x", VideoID: "x", Itag: 18, RequesterID: 1,
+			ExpiresAt: now.Add(time.Hour)},
+		{Token: "b", Action: "page", Value: "2", RequesterID: 1, ExpiresAt: now.Add(-time.Second)},
+	}))
+
+	callback, err := s.Callback(ctx, "a")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "format", callback.Action)
+		assert.Equal(t, "https://youtu.be/x", callback.URL)
+		assert.Equal(t, 18, callback.Itag)
+		assert.Equal(t, int64(1), callback.RequesterID)
+	}
+
+	_, err = s.Callback(ctx, "b")
+	assert.ErrorIs(t, err, ErrCallbackNotFound)
+	_, err = s.Callback(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrCallbackNotFound)
+
+	deleted, err := s.DeleteExpiredCallbacks(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Chat is a private chat of a user with the bot, broadcasts are sent to known chats
+type Chat struct {
+	ChatID       int64
+	UserID       int64
+	Username     string
+	LanguageCode string
+	Blocked      bool // the user blocked the bot, so messages can't be sent to the chat
+	FirstSeen    time.Time
+	LastSeen     time.Time
+}
+
+// Broadcast is a message sent by an admin to an audience of users with its delivery progress
+type Broadcast struct {
+	ID         int64
+	AdminID    int64
+	FromChatID int64 // the message to broadcast is copied from this chat
+	MessageID  int
+	Audience   string
+	State      string
+	Total      int
+	Sent       int
+	Failed     int
+	Blocked    int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// SaveChat saves the chat or updates the last time it was seen.
+// A chat of a user who wrote to the bot isn't blocked anymore
+func (s *Store) SaveChat(ctx context.Context, chat *Chat) error {
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO chats
+		(chat_id, user_id, username, language_code, blocked, first_seen, last_seen) VALUES (?, ?, ?, ?, 0, ?, ?)
+		ON CONFLICT (chat_id) DO UPDATE SET
+		user_id = excluded.user_id, username = excluded.username, language_code = excluded.language_code,
+		blocked = 0, last_seen = excluded.last_seen`,
+		chat.ChatID, chat.UserID, chat.Username, chat.LanguageCode, now.Unix(), now.Unix())
+	return err
+}
+
+// SetChatBlocked marks the chat as blocked by its user
+func (s *Store) SetChatBlocked(ctx context.Context, chatID int64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE chats SET blocked = 1 WHERE chat_id = ?`, chatID)
+	return err
+}
+
+// Chats return chats which aren't blocked in order they were seen first
+func (s *Store) Chats(ctx context.Context) ([]Chat, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT chat_id, user_id, username, language_code, blocked, first_seen, last_seen
+		FROM chats WHERE blocked = 0 ORDER BY first_seen, chat_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []Chat
+	for rows.Next() {
+		var chat Chat
+		var firstSeen, lastSeen int64
+		err := rows.Scan(&chat.ChatID, &chat.UserID, &chat.Username, &chat.LanguageCode, &chat.Blocked,
+			&firstSeen, &lastSeen)
+		if err != nil {
+			return nil, err
+		}
+		chat.FirstSeen = time.Unix(firstSeen, 0)
+		chat.LastSeen = time.Unix(lastSeen, 0)
+		chats = append(chats, chat)
+	}
+	return chats, rows.Err()
+}
+
+// SaveBroadcast inserts the broadcast or updates its state and progress
+func (s *Store) SaveBroadcast(ctx context.Context, broadcast *Broadcast) error {
+	now := time.Now()
+	if broadcast.CreatedAt.IsZero() {
+		broadcast.CreatedAt = now
+	}
+	broadcast.UpdatedAt = now
+
+	if broadcast.ID != 0 {
+		_, err := s.db.ExecContext(ctx, `UPDATE broadcasts
+			SET state = ?, total = ?, sent = ?, failed = ?, blocked = ?, updated_at = ? WHERE id = ?`,
+			broadcast.State, broadcast.Total, broadcast.Sent, broadcast.Failed, broadcast.Blocked,
+			broadcast.UpdatedAt.Unix(), broadcast.ID)
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, `INSERT INTO broadcasts
+		(admin_id, from_chat_id, message_id, audience, state, total, sent, failed, blocked, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		broadcast.AdminID, broadcast.FromChatID, broadcast.MessageID, broadcast.Audience, broadcast.State,
+		broadcast.Total, broadcast.Sent, broadcast.Failed, broadcast.Blocked,
+		broadcast.CreatedAt.Unix(), broadcast.UpdatedAt.Unix())
+	if err != nil {
+		return err
+	}
+	broadcast.ID, err = res.LastInsertId()
+	return err
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestChats(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	assert.NoError(t, s.SaveChat(ctx, &Chat{ChatID: 1, UserID: 1, Username: "a"}))
+	assert.NoError(t, s.SaveChat(ctx, &Chat{ChatID: 2, UserID: 2, Username: "b"}))
+	assert.NoError(t, s.SaveChat(ctx, &Chat{ChatID: 1, UserID: 1, Username: "renamed"}))
+	assert.NoError(t, s.SetChatBlocked(ctx, 2))
+
+	chats, err := s.Chats(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, chats, 1) {
+		assert.Equal(t, "renamed", chats[0].Username)
+	}
+
+	// the user wrote to the bot again after blocking it
+	assert.NoError(t, s.SaveChat(ctx, &Chat{ChatID: 2, UserID: 2, Username: "b"}))
+	chats, err = s.Chats(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, chats, 2)
+}
+
+func TestBroadcasts(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	broadcast := &Broadcast{AdminID: 1, FromChatID: 1, MessageID: 10, Audience: "all", State: "sending", Total: 2}
+	assert.NoError(t, s.SaveBroadcast(ctx, broadcast))
+	assert.NotZero(t, broadcast.ID)
+
+	broadcast.Sent, broadcast.Blocked, broadcast.State = 1, 1, "done"
+	assert.NoError(t, s.SaveBroadcast(ctx, broadcast))
+
+	var state string
+	var sent, blocked int
+	err = s.db.QueryRow(`SELECT state, sent, blocked FROM broadcasts WHERE id = ?`, broadcast.ID).Scan(&state, &sent, &blocked)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", state)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 1, blocked)
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SetGroupAutoMode turns on or off the auto mode of the group, the bot handles every link in the group in it
+func (s *Store) SetGroupAutoMode(ctx context.Context, chatID int64, on bool) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO group_settings (chat_id, auto_mode, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (chat_id) DO UPDATE SET auto_mode = excluded.auto_mode, updated_at = excluded.updated_at`,
+		chatID, on, time.Now().Unix())
+	return err
+}
+
+// GroupAutoMode return true if the auto mode of the group is on, it's off by default
+func (s *Store) GroupAutoMode(ctx context.Context, chatID int64) (bool, error) {
+	var on bool
+	err := s.db.QueryRowContext(ctx, `SELECT auto_mode FROM group_settings WHERE chat_id = ?`, chatID).Scan(&on)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return on, err
+}
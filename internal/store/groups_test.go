@@ -0,0 +1,29 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestGroupAutoMode(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	on, err := s.GroupAutoMode(ctx, -100)
+	assert.NoError(t, err)
+	assert.False(t, on)
+
+	assert.NoError(t, s.SetGroupAutoMode(ctx, -100, true))
+	on, err = s.GroupAutoMode(ctx, -100)
+	assert.NoError(t, err)
+	assert.True(t, on)
+
+	assert.NoError(t, s.SetGroupAutoMode(ctx, -100, false))
+	on, err = s.GroupAutoMode(ctx, -100)
+	assert.NoError(t, err)
+	assert.False(t, on)
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Job is a saved job of the queue with everything needed to resume it after a restart
+type Job struct {
+	ID              string
+	UserID          int64
+	Username        string
+	LanguageCode    string
+	ChatID          int64
+	MessageID       int // files are sent in reply to this message
+	StatusMessageID int // the message showing the job's state, 0 if it isn't sent yet
+	Kind            string
+	URL             string
+	Itag            int
+	Title           string
+	State           string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// unfinishedStates are states of jobs which were queued or running when the bot stopped
+const unfinishedStates = `('queued', 'downloading', 'merging', 'uploading')`
+
+// SaveJob inserts the job or replaces the saved one with the same id
+func (s *Store) SaveJob(ctx context.Context, job *Job) error {
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO jobs
+		(id, user_id, username, language_code, chat_id, message_id, status_message_id, kind, url, itag, title, state, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.UserID, job.Username, job.LanguageCode, job.ChatID, job.MessageID, job.StatusMessageID,
+		job.Kind, job.URL, job.Itag, job.Title, job.State, job.CreatedAt.Unix(), job.UpdatedAt.Unix())
+	return err
+}
+
+// SetJobState updates the state of the job
+func (s *Store) SetJobState(ctx context.Context, id string, state string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE jobs SET state = ?, updated_at = ? WHERE id = ?`,
+		state, time.Now().Unix(), id)
+	return err
+}
+
+// SetJobStatusMessage updates the message showing the state of the job
+func (s *Store) SetJobStatusMessage(ctx context.Context, id string, messageID int) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE jobs SET status_message_id = ?, updated_at = ? WHERE id = ?`,
+		messageID, time.Now().Unix(), id)
+	return err
+}
+
+// UnfinishedJobs return jobs which were queued or running in order of their creation
+func (s *Store) UnfinishedJobs(ctx context.Context) ([]Job, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT
+		id, user_id, username, language_code, chat_id, message_id, status_message_id, kind, url, itag, title, state, created_at, updated_at
+		FROM jobs WHERE state IN `+unfinishedStates+` ORDER BY created_at, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var job Job
+		var createdAt, updatedAt int64
+		err := rows.Scan(&job.ID, &job.UserID, &job.Username, &job.LanguageCode, &job.ChatID, &job.MessageID,
+			&job.StatusMessageID, &job.Kind, &job.URL, &job.Itag, &job.Title, &job.State, &createdAt, &updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		job.CreatedAt = time.Unix(createdAt, 0)
+		job.UpdatedAt = time.Unix(updatedAt, 0)
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// DeleteFinishedJobs deletes finished jobs updated before the time
+func (s *Store) DeleteFinishedJobs(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM jobs WHERE state NOT IN `+unfinishedStates+` AND updated_at < ?`,
+		before.Unix())
+	return err
+}
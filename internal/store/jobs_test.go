@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJobs(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	queued := &Job{ID: "1", UserID: 10, ChatID: 20, MessageID: 30, Kind: "format", URL: "https://youtu.be/x", Itag: 18, State: "queued"}
+	done := &Job{ID: "2", UserID: 10, ChatID: 20, Kind: "format", State: "queued"}
+	assert.NoError(t, s.SaveJob(ctx, queued))
+	assert.NoError(t, s.SaveJob(ctx, done))
+	assert.NoError(t, s.SetJobState(ctx, "2", "done"))
+	assert.NoError(t, s.SetJobStatusMessage(ctx, "1", 40))
+
+	jobs, err := s.UnfinishedJobs(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, "1", jobs[0].ID)
+		assert.Equal(t, 18, jobs[0].Itag)
+		assert.Equal(t, 40, jobs[0].StatusMessageID)
+		assert.Equal(t, "https://youtu.be/x", jobs[0].URL)
+	}
+
+	count := func() (n int) {
+		assert.NoError(t, s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM jobs`).Scan(&n))
+		return n
+	}
+	// the finished job is kept until it's older than the cutoff
+	assert.NoError(t, s.DeleteFinishedJobs(ctx, time.Now().Add(-time.Minute)))
+	assert.Equal(t, 2, count())
+
+	assert.NoError(t, s.DeleteFinishedJobs(ctx, time.Now().Add(time.Minute)))
+	assert.Equal(t, 1, count())
+	jobs, err = s.UnfinishedJobs(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, jobs, 1) {
+		assert.Equal(t, "1", jobs[0].ID)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+	s, err := Open(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.SaveJob(context.Background(), &Job{ID: "1", State: "downloading"}))
+	assert.NoError(t, s.Close())
+
+	// migrations aren't applied twice
+	s, err = Open(path)
+	assert.NoError(t, err)
+	defer s.Close()
+	jobs, err := s.UnfinishedJobs(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Selection is a set of entries selected in a playlist keyboard, it's kept by the keyboard's message
+type Selection struct {
+	ChatID     int64
+	MessageID  int
+	VideoIDs   []string
+	RangeMode  bool   // the next pressed entries select a range instead of toggling
+	RangeStart string // the first entry of the range, it's empty until it's pressed
+	UpdatedAt  time.Time
+}
+
+// Selection return the selection of the keyboard's message, an empty one if nothing was selected in it
+func (s *Store) Selection(ctx context.Context, chatID int64, messageID int) (*Selection, error) {
+	selection := Selection{ChatID: chatID, MessageID: messageID}
+	var videoIDs string
+	var updatedAt int64
+	err := s.db.QueryRowContext(ctx, `SELECT video_ids, range_mode, range_start, updated_at
+		FROM selections WHERE chat_id = ? AND message_id = ?`, chatID, messageID).
+		Scan(&videoIDs, &selection.RangeMode, &selection.RangeStart, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &selection, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if videoIDs != "" {
+		selection.VideoIDs = strings.Split(videoIDs, ",")
+	}
+	selection.UpdatedAt = time.Unix(updatedAt, 0)
+	return &selection, nil
+}
+
+// SaveSelection saves the selection of the keyboard's message
+func (s *Store) SaveSelection(ctx context.Context, selection *Selection) error {
+	selection.UpdatedAt = time.Now()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO selections
+		(chat_id, message_id, video_ids, range_mode, range_start, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_id, message_id) DO UPDATE SET
+		video_ids = excluded.video_ids, range_mode = excluded.range_mode, range_start = excluded.range_start,
+		updated_at = excluded.updated_at`,
+		selection.ChatID, selection.MessageID, strings.Join(selection.VideoIDs, ","), selection.RangeMode,
+		selection.RangeStart, selection.UpdatedAt.Unix())
+	return err
+}
+
+// DeleteSelectionsBefore deletes selections which weren't changed since the time and return their number
+func (s *Store) DeleteSelectionsBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM selections WHERE updated_at < ?`, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
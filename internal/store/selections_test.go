@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSelections(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	selection, err := s.Selection(ctx, 1, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, selection.VideoIDs)
+
+	selection.VideoIDs = []string{"a", "b"}
+	selection.RangeMode, selection.RangeStart = true, "b"
+	assert.NoError(t, s.SaveSelection(ctx, selection))
+
+	selection, err = s.Selection(ctx, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, selection.VideoIDs)
+	assert.True(t, selection.RangeMode)
+	assert.Equal(t, "b", selection.RangeStart)
+
+	// keyboards of other messages have their own selections
+	other, err := s.Selection(ctx, 1, 11)
+	assert.NoError(t, err)
+	assert.Empty(t, other.VideoIDs)
+
+	deleted, err := s.DeleteSelectionsBefore(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
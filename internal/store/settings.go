@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrSettingsNotFound is returned for a user who didn't change settings
+var ErrSettingsNotFound = errors.New("settings not found")
+
+// Settings are preferences of a user for downloads
+type Settings struct {
+	UserID     int64
+	Mode       string // what to do with a link of a video, i.e. ask for a format or download the best audio
+	MaxHeight  int    // the max resolution of videos downloaded automatically, 0 means any
+	AudioCodec string
+	AsDocument bool // files are sent as documents, so Telegram doesn't compress them
+	Caption    string
+	Language   string // the language chosen by /language, empty for the language of the user's Telegram
+	UpdatedAt  time.Time
+}
+
+// Settings return settings of the user, ErrSettingsNotFound if they weren't saved
+func (s *Store) Settings(ctx context.Context, userID int64) (*Settings, error) {
+	settings := Settings{UserID: userID}
+	var updatedAt int64
+	err := s.db.QueryRowContext(ctx, `SELECT mode, max_height, audio_codec, as_document, caption, language,
+		updated_at FROM user_settings WHERE user_id = ?`, userID).
+		Scan(&settings.Mode, &settings.MaxHeight, &settings.AudioCodec, &settings.AsDocument, &settings.Caption,
+			&settings.Language, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSettingsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	settings.UpdatedAt = time.Unix(updatedAt, 0)
+	return &settings, nil
+}
+
+// SaveSettings saves settings of the user
+func (s *Store) SaveSettings(ctx context.Context, settings *Settings) error {
+	settings.UpdatedAt = time.Now()
+	_, err := s.db.ExecContext(ctx, `INSERT INTO user_settings
+		(user_id, mode, max_height, audio_codec, as_document, caption, language, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+		mode = excluded.mode, max_height = excluded.max_height, audio_codec = excluded.audio_codec,
+		as_document = excluded.as_document, caption = excluded.caption, language = excluded.language,
+		updated_at = excluded.updated_at`,
+		settings.UserID, settings.Mode, settings.MaxHeight, settings.AudioCodec, settings.AsDocument,
+		settings.Caption, settings.Language, settings.UpdatedAt.Unix())
+	return err
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	assert.NoError(t, err)
+	defer s.Close()
+	ctx := context.Background()
+
+	_, err = s.Settings(ctx, 1)
+	assert.ErrorIs(t, err, ErrSettingsNotFound)
+
+	settings := &Settings{UserID: 1, Mode: "audio", MaxHeight: 720, AudioCodec: "opus", AsDocument: true, Caption: "none",
+		Language: "ru"}
+	assert.NoError(t, s.SaveSettings(ctx, settings))
+	settings.Mode = "video"
+	assert.NoError(t, s.SaveSettings(ctx, settings))
+
+	saved, err := s.Settings(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "video", saved.Mode)
+	assert.Equal(t, 720, saved.MaxHeight)
+	assert.Equal(t, "opus", saved.AudioCodec)
+	assert.True(t, saved.AsDocument)
+	assert.Equal(t, "none", saved.Caption)
+	assert.Equal(t, "ru", saved.Language)
+}
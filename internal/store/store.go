@@ -0,0 +1,167 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"os"
+	"path/filepath"
+)
+
+// DefaultPath is a path of the database if STORE_PATH isn't set
+const DefaultPath = "data/bot.db"
+
+// migrations create the schema step by step, the number of applied ones is kept in user_version.
+// Append new migrations to the end, never change the applied ones
+var migrations = []string{
+	`CREATE TABLE jobs (
+		id                TEXT PRIMARY KEY,
+		user_id           INTEGER NOT NULL,
+		username          TEXT NOT NULL,
+		language_code     TEXT NOT NULL,
+		chat_id           INTEGER NOT NULL,
+		message_id        INTEGER NOT NULL,
+		status_message_id INTEGER NOT NULL,
+		kind              TEXT NOT NULL,
+		url               TEXT NOT NULL,
+		itag              INTEGER NOT NULL,
+		title             TEXT NOT NULL,
+		state             TEXT NOT NULL,
+		created_at        INTEGER NOT NULL,
+		updated_at        INTEGER NOT NULL
+	);
+	CREATE INDEX jobs_state ON jobs (state);`,
+	`CREATE TABLE bans (
+		target     TEXT PRIMARY KEY,
+		reason     TEXT NOT NULL,
+		admin_id   INTEGER NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE audit_log (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		admin_id       INTEGER NOT NULL,
+		admin_username TEXT NOT NULL,
+		action         TEXT NOT NULL,
+		target         TEXT NOT NULL,
+		details        TEXT NOT NULL,
+		created_at     INTEGER NOT NULL
+	);
+	CREATE INDEX jobs_created_at ON jobs (created_at);`,
+	`CREATE TABLE chats (
+		chat_id       INTEGER PRIMARY KEY,
+		user_id       INTEGER NOT NULL,
+		username      TEXT NOT NULL,
+		language_code TEXT NOT NULL,
+		blocked       INTEGER NOT NULL DEFAULT 0,
+		first_seen    INTEGER NOT NULL,
+		last_seen     INTEGER NOT NULL
+	);
+	CREATE TABLE broadcasts (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		admin_id     INTEGER NOT NULL,
+		from_chat_id INTEGER NOT NULL,
+		message_id   INTEGER NOT NULL,
+		audience     TEXT NOT NULL,
+		state        TEXT NOT NULL,
+		total        INTEGER NOT NULL,
+		sent         INTEGER NOT NULL,
+		failed       INTEGER NOT NULL,
+		blocked      INTEGER NOT NULL,
+		created_at   INTEGER NOT NULL,
+		updated_at   INTEGER NOT NULL
+	);`,
+	`CREATE TABLE group_settings (
+		chat_id    INTEGER PRIMARY KEY,
+		auto_mode  INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);`,
+	`CREATE TABLE callbacks (
+		token        TEXT PRIMARY KEY,
+		action       TEXT NOT NULL,
+		url          TEXT NOT NULL,
+		video_id     TEXT NOT NULL,
+		itag         INTEGER NOT NULL,
+		value        TEXT NOT NULL,
+		requester_id INTEGER NOT NULL,
+		created_at   INTEGER NOT NULL,
+		expires_at   INTEGER NOT NULL
+	);
+	CREATE INDEX callbacks_expires_at ON callbacks (expires_at);`,
+	`CREATE TABLE selections (
+		chat_id     INTEGER NOT NULL,
+		message_id  INTEGER NOT NULL,
+		video_ids   TEXT NOT NULL,
+		range_mode  INTEGER NOT NULL,
+		range_start TEXT NOT NULL,
+		updated_at  INTEGER NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	);`,
+	`CREATE TABLE user_settings (
+		user_id     INTEGER PRIMARY KEY,
+		mode        TEXT NOT NULL,
+		max_height  INTEGER NOT NULL,
+		audio_codec TEXT NOT NULL,
+		as_document INTEGER NOT NULL,
+		caption     TEXT NOT NULL,
+		updated_at  INTEGER NOT NULL
+	);`,
+	`ALTER TABLE user_settings ADD COLUMN language TEXT NOT NULL DEFAULT '';`,
+}
+
+// Store is a local SQLite database for the bot's own data which has to survive restarts
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the database by the path, creating it if needed, and migrates its schema
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("could not create store dir: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		return nil, fmt.Errorf("could not open store: %w", err)
+	}
+	// SQLite allows a single writer, so the pool is kept to one connection
+	db.SetMaxOpenConns(1)
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies migrations which weren't applied yet
+func (s *Store) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("could not get store version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
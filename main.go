@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
@@ -9,11 +10,14 @@ import (
 	"os/signal"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"syscall"
 	"youtube_downloader/internal/bot/tg"
+	youtube_downloader "youtube_downloader/internal/downloader/youtube"
 )
 
-// startProfiling initializes CPU and memory profiling and sets up signal handling for graceful shutdown.
+// startProfiling initializes CPU and memory profiling.
+// The returned cleanup stops CPU profiling and writes the memory profile
 func startProfiling(cpuProfile, memProfile string) (cleanup func(), err error) {
 	// Start CPU profiling
 	cpuFile, err := os.Create(cpuProfile)
@@ -33,34 +37,15 @@ func startProfiling(cpuProfile, memProfile string) (cleanup func(), err error) {
 		return nil, err
 	}
 
-	// Handle system signals for graceful shutdown
-	sigs := make(chan os.Signal, 1)
-	done := make(chan bool, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		sig := <-sigs
-		log.Println(sig)
-		pprof.StopCPUProfile()
-		cpuFile.Close()
-		runtime.GC() // Forcing garbage collection to get accurate memory profile
-		if err := pprof.WriteHeapProfile(memFile); err != nil {
-			log.Fatal("could not write memory profile: ", err)
-		}
-		memFile.Close()
-		done <- true
-	}()
-
 	// Cleanup function to stop profiling and close files
 	cleanup = func() {
 		pprof.StopCPUProfile()
 		cpuFile.Close()
-		runtime.GC()
+		runtime.GC() // Forcing garbage collection to get accurate memory profile
 		if err := pprof.WriteHeapProfile(memFile); err != nil {
-			log.Fatal("could not write memory profile: ", err)
+			log.Println("could not write memory profile: ", err)
 		}
 		memFile.Close()
-		done <- true
 	}
 
 	return cleanup, nil
@@ -72,7 +57,14 @@ func main() {
 	if err != nil {
 		log.Fatal("Error starting profiling: ", err)
 	}
-	defer cleanup()
+
+	// SIGINT/SIGTERM start the graceful shutdown, a second signal kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		log.Println("Shutdown signal received, send it again to exit immediately")
+		stop()
+	}()
 
 	err = godotenv.Load()
 	if err != nil {
@@ -84,6 +76,26 @@ func main() {
 		log.Fatal("TELEGRAM_BOT_TOKEN must be set")
 	}
 
+	// YOUTUBE_PROXIES is a comma separated list of http://, https:// or socks5:// proxies for YouTube requests
+	if proxies := os.Getenv("YOUTUBE_PROXIES"); proxies != "" {
+		pool, err := youtube_downloader.NewProxyPool(strings.Split(proxies, ","))
+		if err != nil {
+			log.Fatal("Error configuring proxies: ", err)
+		}
+		youtube_downloader.SetProxyPool(pool)
+		log.Printf("Using %d proxies for YouTube requests", pool.Len())
+	}
+
+	// YOUTUBE_COOKIES_FILE is a cookies.txt of a YouTube account for age-restricted and members-only videos
+	if cookiesFile := os.Getenv("YOUTUBE_COOKIES_FILE"); cookiesFile != "" {
+		jar, err := youtube_downloader.LoadCookies(cookiesFile)
+		if err != nil {
+			log.Fatal("Error loading cookies: ", err)
+		}
+		youtube_downloader.SetCookies(jar)
+		log.Printf("Using cookies from %s for YouTube requests", cookiesFile)
+	}
+
 	host := os.Getenv("HOST") // telegram-bot-api:8081 (docker network) || localhost:8081 || empty "" for default without botAPIEndpoint
 	var botAPIEndpoint string
 	var botAPI *tgbotapi.BotAPI
@@ -99,8 +111,11 @@ func main() {
 	}
 
 	tgBot := tg.BotInstance(botAPI)
-	tgBot.SetCommands()
-	if err := tgBot.StartBot(); err != nil {
-		log.Fatal(err)
+	err = tgBot.StartBot(ctx)
+	// profiles are written before exit, deferred calls don't run on os.Exit
+	cleanup()
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
 	}
 }